@@ -25,12 +25,14 @@ import (
 	"github.com/spf13/cobra"
 
 	"sigs.k8s.io/controller-tools/pkg/crd"
+	"sigs.k8s.io/controller-tools/pkg/crd/migrate"
 	"sigs.k8s.io/controller-tools/pkg/deepcopy"
 	"sigs.k8s.io/controller-tools/pkg/genall"
 	"sigs.k8s.io/controller-tools/pkg/genall/help"
 	prettyhelp "sigs.k8s.io/controller-tools/pkg/genall/help/pretty"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 	"sigs.k8s.io/controller-tools/pkg/rbac"
+	"sigs.k8s.io/controller-tools/pkg/rbacpatcher"
 	"sigs.k8s.io/controller-tools/pkg/schemapatcher"
 	"sigs.k8s.io/controller-tools/pkg/version"
 	"sigs.k8s.io/controller-tools/pkg/webhook"
@@ -48,11 +50,13 @@ var (
 	// each turns into a command line option,
 	// and has options for output forms.
 	allGenerators = map[string]genall.Generator{
-		"crd":         crd.Generator{},
-		"rbac":        rbac.Generator{},
-		"object":      deepcopy.Generator{},
-		"webhook":     webhook.Generator{},
-		"schemapatch": schemapatcher.Generator{},
+		"crd":          crd.Generator{},
+		"rbac":         rbac.Generator{},
+		"object":       deepcopy.Generator{},
+		"webhook":      webhook.Generator{},
+		"schemapatch":  schemapatcher.Generator{},
+		"migrate-crds": migrate.Generator{},
+		"rbacpatch":    rbacpatcher.Generator{},
 	}
 
 	// allOutputRules defines the list of all known output rules, giving
@@ -141,6 +145,9 @@ func main() {
 	# Generate OpenAPI v3 schemas for API packages and merge them into existing CRD manifests
 	controller-gen schemapatch:manifests=./manifests output:dir=./manifests paths=./pkg/apis/... 
 
+	# Replace the rules in existing ClusterRole and Role manifests, keeping everything else
+	controller-gen rbacpatch:manifests=./config/rbac,roleName=manager-role output:dir=./config/rbac paths=./...
+
 	# Run all the generators for a given project
 	controller-gen paths=./apis/...
 
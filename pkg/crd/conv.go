@@ -41,6 +41,28 @@ func AsVersion(original apiext.CustomResourceDefinition, gv schema.GroupVersion)
 	return conversionScheme.ConvertToVersion(intVer, gv)
 }
 
+// FromLegacy converts a legacy (v1beta1) CRD to the canonical form (currently v1),
+// the opposite of AsVersion.  The v1beta1 defaults are applied first (which, e.g.,
+// turns the deprecated single version into a list of versions), since
+// hand-written manifests generally rely on them.
+//
+// Global schemata, subresources and printer columns end up on every version,
+// since v1 only has per-version ones.
+func FromLegacy(legacy apiextv1beta1.CustomResourceDefinition) (*apiext.CustomResourceDefinition, error) {
+	defaulted := legacy.DeepCopy()
+	apiextv1beta1.SetObjectDefaults_CustomResourceDefinition(defaulted)
+
+	intVer, err := conversionScheme.ConvertToVersion(defaulted, apiextinternal.SchemeGroupVersion)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert to internal CRD version: %w", err)
+	}
+	converted, err := conversionScheme.ConvertToVersion(intVer, apiext.SchemeGroupVersion)
+	if err != nil {
+		return nil, err
+	}
+	return converted.(*apiext.CustomResourceDefinition), nil
+}
+
 // mergeIdenticalSubresources checks to see if subresources are identical across
 // all versions, and if so, merges them into a top-level version.
 //
@@ -21,9 +21,23 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	pkgstest "golang.org/x/tools/go/packages/packagestest"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	testloader "sigs.k8s.io/controller-tools/pkg/loader/testutils"
 )
 
 func TestCRDGeneration(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "CRD Generation Suite")
 }
+
+// loadFakePackage loads a fake package with the given path, made up of the
+// given files, returning it along with a function cleaning it up.
+func loadFakePackage(pkgPath string, files map[string]interface{}) (*loader.Package, func()) {
+	modules := []pkgstest.Module{{Name: pkgPath, Files: files}}
+	pkgs, exported, err := testloader.LoadFakeRoots(pkgstest.Modules, modules, pkgPath)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	ExpectWithOffset(1, pkgs).To(HaveLen(1))
+	return pkgs[0], exported.Cleanup
+}
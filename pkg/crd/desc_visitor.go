@@ -30,11 +30,22 @@ func TruncateDescription(schema *apiext.JSONSchemaProps, maxLen int) {
 	EditSchema(schema, descVisitor{maxLen: maxLen})
 }
 
+// truncateDescriptionBelow works like TruncateDescription, but leaves the
+// descriptions of schema nodes shallower than minDepth untouched (the given
+// schema itself is at depth 0).
+func truncateDescriptionBelow(schema *apiext.JSONSchemaProps, maxLen int, minDepth int) {
+	EditSchema(schema, descVisitor{maxLen: maxLen, minDepth: minDepth})
+}
+
 // descVisitor recursively visits all fields in the schema and truncates the
 // description of the fields to specified maxLen.
 type descVisitor struct {
 	// maxLen is the maximum allowed length for decription of a field
 	maxLen int
+	// minDepth is the shallowest depth at which descriptions get truncated.
+	minDepth int
+	// depth is the depth of the schema currently being visited.
+	depth int
 }
 
 func (v descVisitor) Visit(schema *apiext.JSONSchemaProps) SchemaVisitor {
@@ -44,15 +55,20 @@ func (v descVisitor) Visit(schema *apiext.JSONSchemaProps) SchemaVisitor {
 	if v.maxLen < 0 {
 		return nil /* no further work to be done for this schema */
 	}
+	child := v
+	child.depth++
+	if v.depth < v.minDepth {
+		return child
+	}
 	if v.maxLen == 0 {
 		schema.Description = ""
-		return v
+		return child
 	}
 	if len(schema.Description) > v.maxLen {
 		schema.Description = truncateString(schema.Description, v.maxLen)
-		return v
+		return child
 	}
-	return v
+	return child
 }
 
 // truncateString truncates given desc string if it exceeds maxLen. It may
@@ -0,0 +1,136 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var _ = Describe("Feature gated schemata", func() {
+	var (
+		pkg     *loader.Package
+		cleanup func()
+	)
+
+	BeforeEach(func() {
+		pkg, cleanup = loadFakePackage("sigs.k8s.io/controller-tools/pkg/crd/gatetest", map[string]interface{}{
+			"types.go": `
+				package gatetest
+
+				type Spec struct {
+					// +kubebuilder:validation:MaxLength=10
+					// +kubebuilder:validation:FeatureGated:featureGate=Preview,minLength=5,maxLength=8,pattern=^a
+					Stable string ` + "`json:\"stable\"`" + `
+
+					// +kubebuilder:featureGate=Preview
+					Preview string ` + "`json:\"preview\"`" + `
+
+					// +kubebuilder:featureGate=Preview
+					// +kubebuilder:featureGate=Other
+					Both string ` + "`json:\"both\"`" + `
+
+					// +kubebuilder:validation:Enum=A;B
+					// +kubebuilder:validation:FeatureGatedEnum:featureGate=Preview,values=C;D
+					Mode string ` + "`json:\"mode\"`" + `
+				}
+
+				type Unrestricted struct {
+					// +kubebuilder:validation:FeatureGatedEnum:featureGate=Preview,values=C;D
+					Mode string ` + "`json:\"mode\"`" + `
+				}
+			`,
+		})
+	})
+
+	AfterEach(func() {
+		cleanup()
+	})
+
+	parserWithGates := func(gates map[string]bool) *crd.Parser {
+		reg := &markers.Registry{}
+		Expect(crdmarkers.Register(reg)).To(Succeed())
+		parser := &crd.Parser{
+			Collector:    &markers.Collector{Registry: reg},
+			Checker:      &loader.TypeChecker{},
+			FeatureGates: gates,
+		}
+		parser.NeedPackage(pkg)
+		return parser
+	}
+
+	schemaWithGates := func(gates map[string]bool) apiext.JSONSchemaProps {
+		parser := parserWithGates(gates)
+		typ := crd.TypeIdent{Package: pkg, Name: "Spec"}
+		parser.NeedSchemaFor(typ)
+		Expect(pkg.Errors).To(BeEmpty())
+		return parser.Schemata[typ]
+	}
+
+	enumOf := func(props apiext.JSONSchemaProps) []string {
+		var vals []string
+		for _, val := range props.Enum {
+			vals = append(vals, string(val.Raw))
+		}
+		return vals
+	}
+
+	It("should include everything when gating is disabled", func() {
+		schema := schemaWithGates(nil)
+		Expect(schema.Properties).To(HaveKey("preview"))
+		Expect(schema.Properties).To(HaveKey("both"))
+		Expect(enumOf(schema.Properties["mode"])).To(Equal([]string{`"A"`, `"B"`, `"C"`, `"D"`}))
+		Expect(*schema.Properties["stable"].MinLength).To(Equal(int64(5)))
+		Expect(*schema.Properties["stable"].MaxLength).To(Equal(int64(8)))
+		Expect(schema.Properties["stable"].Pattern).To(Equal("^a"))
+	})
+
+	It("should leave out gated fields and values when their gates are disabled", func() {
+		schema := schemaWithGates(map[string]bool{})
+		Expect(schema.Properties).To(HaveKey("stable"))
+		Expect(schema.Properties).NotTo(HaveKey("preview"))
+		Expect(schema.Properties).NotTo(HaveKey("both"))
+		Expect(schema.Required).To(ConsistOf("stable", "mode"))
+		Expect(enumOf(schema.Properties["mode"])).To(Equal([]string{`"A"`, `"B"`}))
+		Expect(schema.Properties["stable"].MinLength).To(BeNil())
+		Expect(*schema.Properties["stable"].MaxLength).To(Equal(int64(10)))
+		Expect(schema.Properties["stable"].Pattern).To(BeEmpty())
+	})
+
+	It("should reject gated enum values without a base enum, whether or not their gate is enabled", func() {
+		for _, gates := range []map[string]bool{nil, {}} {
+			pkg.Errors = nil
+			parser := parserWithGates(gates)
+			parser.NeedSchemaFor(crd.TypeIdent{Package: pkg, Name: "Unrestricted"})
+			Expect(pkg.Errors).To(HaveLen(1))
+			Expect(pkg.Errors[0].Msg).To(ContainSubstring("FeatureGatedEnum requires the Enum marker"))
+		}
+	})
+
+	It("should only include fields when all of their gates are enabled", func() {
+		schema := schemaWithGates(map[string]bool{"Preview": true})
+		Expect(schema.Properties).To(HaveKey("preview"))
+		Expect(schema.Properties).NotTo(HaveKey("both"))
+		Expect(enumOf(schema.Properties["mode"])).To(Equal([]string{`"A"`, `"B"`, `"C"`, `"D"`}))
+	})
+})
@@ -17,9 +17,12 @@ limitations under the License.
 package crd
 
 import (
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/types"
+	"sort"
+	"strings"
 
 	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextlegacy "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
@@ -83,6 +86,66 @@ type Generator struct {
 	// You'll need to use "v1" to get support for features like defaulting,
 	// along with an API server that supports it (Kubernetes 1.16+).
 	CRDVersions []string `marker:"crdVersions,optional"`
+
+	// MaxSize specifies the maximum size, in bytes, of each generated CRD.
+	//
+	// CRDs larger than this have their descriptions progressively trimmed,
+	// deepest and longest first, until they fit.  Generation fails for
+	// CRDs that are still too large without any descriptions.
+	//
+	// Size is measured as the length of the CRD serialized as JSON, as
+	// stored by `kubectl apply` in the last-applied-configuration
+	// annotation (which is limited to 262144 bytes in total).  The size of
+	// each CRD before trimming is listed in the report, if one is requested.
+	MaxSize *int `marker:",optional"`
+
+	// Report specifies the path of a report on the size and complexity of
+	// each generated CRD, relative to the output location of this generator.
+	//
+	// The report lists the serialized size of each CRD and the schema of each of its
+	// versions, the largest fields, the maximum nesting depth, the number of
+	// properties, and the external types contributing the most to the size of
+	// each schema.  It's written as JSON if the path ends in `.json`, and as
+	// Markdown if it ends in `.md`.
+	Report string `marker:",optional"`
+
+	// InferListTypes indicates that list types should be inferred for list
+	// fields that don't have a listType marker, so that server-side apply
+	// can merge them.
+	//
+	// Lists of scalars are treated as atomic.  Lists of objects are treated
+	// as associative lists if the field has a patchMergeKey struct tag, or
+	// the item type has listMapKey markers, as long as the keys refer to
+	// required (or defaulted) scalar fields.  Inferred list types are
+	// listed in the report, if one is requested.
+	InferListTypes bool `marker:",optional"`
+
+	// FeatureSets specifies sets of feature gates to generate CRDs for.
+	//
+	// Each set is given as `Name=GateA+GateB` (or just `Name`, for a set
+	// without any gates enabled).  One variant of each CRD is generated per
+	// set, with the set's name appended to the file name (e.g.
+	// `group_kinds-Name.yaml`).  Each variant only contains the fields,
+	// validations and enum values (see the featureGate, FeatureGated and
+	// FeatureGatedEnum markers) whose gates are enabled in the set.
+	//
+	// Left unspecified, a single variant containing everything is generated.
+	FeatureSets []string `marker:",optional"`
+
+	// TargetKubernetesVersion specifies the oldest Kubernetes version (e.g.
+	// `1.16`) that the generated CRDs must work with.
+	//
+	// Schema features that the target doesn't support (like defaults before
+	// 1.16, or x-kubernetes-map-type before 1.17) are removed, and each
+	// removal is listed in the report.  Without a report, generation fails
+	// instead, listing the features that would have been removed.  Features newer
+	// than the apiextensions types controller-gen is built against (like CEL
+	// validation rules and selectable fields) are never generated, so they
+	// don't need to be removed.  CRD versions that the target doesn't serve
+	// (v1 before 1.16, v1beta1 from 1.22 on) are refused.
+	//
+	// Left unspecified, all features are kept.
+	TargetKubernetesVersion string `marker:",optional"`
 }
 
 func (Generator) CheckFilter() loader.NodeFilter {
@@ -92,11 +155,89 @@ func (Generator) RegisterMarkers(into *markers.Registry) error {
 	return crdmarkers.Register(into)
 }
 func (g Generator) Generate(ctx *genall.GenerationContext) error {
+	// check the report format upfront, so as not to write anything before failing
+	if g.Report != "" {
+		if err := checkReportFormat(g.Report); err != nil {
+			return err
+		}
+	}
+
+	featureSets, err := parseFeatureSets(g.FeatureSets)
+	if err != nil {
+		return err
+	}
+	if len(featureSets) == 0 {
+		// no gating, just a single set of CRDs with everything
+		featureSets = []featureSet{{}}
+	}
+
+	var files []crdFile
+	var reports []KindReport
+	for _, set := range featureSets {
+		setFiles, setReports, err := g.generateFeatureSet(ctx, set)
+		if err != nil {
+			return err
+		}
+		files = append(files, setFiles...)
+		reports = append(reports, setReports...)
+	}
+
+	if g.Report == "" {
+		// without a report, there'd be no trace of removed features
+		var removed []string
+		for _, report := range reports {
+			for _, removal := range report.RemovedFeatures {
+				removed = append(removed, report.Name+": "+removal)
+			}
+		}
+		if len(removed) > 0 {
+			return fmt.Errorf("%d schema feature(s) unsupported by Kubernetes %s (request a report to allow removing them):\n%s",
+				len(removed), g.TargetKubernetesVersion, strings.Join(removed, "\n"))
+		}
+	}
+
+	for _, file := range files {
+		if err := ctx.WriteYAML(file.name, file.obj); err != nil {
+			return err
+		}
+	}
+
+	if g.Report != "" {
+		// kinds come from a map, so sort the reports to keep the output stable
+		sort.SliceStable(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+		out, err := ctx.Open(nil, g.Report)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		if err := writeReport(out, g.Report, reports); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// crdFile is a generated CRD, along with the name of the file to write it to.
+type crdFile struct {
+	name string
+	obj  interface{}
+}
+
+// generateFeatureSet generates the CRDs for the given feature set, returning
+// them along with their reports.
+//
+// Reports are always returned when features are removed for the target
+// Kubernetes version, so that the removals can be checked, and otherwise
+// only if requested.
+func (g Generator) generateFeatureSet(ctx *genall.GenerationContext, set featureSet) ([]crdFile, []KindReport, error) {
 	parser := &Parser{
 		Collector: ctx.Collector,
 		Checker:   ctx.Checker,
 		// Perform defaulting here to avoid ambiguity later
 		AllowDangerousTypes: g.AllowDangerousTypes != nil && *g.AllowDangerousTypes == true,
+		InferListTypes:      g.InferListTypes,
+		FeatureGates:        set.gates,
 	}
 
 	AddKnownTypes(parser)
@@ -107,14 +248,14 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 	metav1Pkg := FindMetav1(ctx.Roots)
 	if metav1Pkg == nil {
 		// no objects in the roots, since nothing imported metav1
-		return nil
+		return nil, nil, nil
 	}
 
 	// TODO: allow selecting a specific object
 	kubeKinds := FindKubeKinds(parser, metav1Pkg)
 	if len(kubeKinds) == 0 {
 		// no objects in the roots
-		return nil
+		return nil, nil, nil
 	}
 
 	crdVersions := g.CRDVersions
@@ -123,61 +264,190 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 		crdVersions = []string{defaultVersion}
 	}
 
+	var target *KubeVersion
+	if g.TargetKubernetesVersion != "" {
+		parsed, err := ParseKubeVersion(g.TargetKubernetesVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkTargetCRDVersions(parsed, crdVersions); err != nil {
+			return nil, nil, err
+		}
+		target = &parsed
+	}
+
+	var files []crdFile
+	var reports []KindReport
 	for groupKind := range kubeKinds {
 		parser.NeedCRDFor(groupKind, g.MaxDescLen)
 		crdRaw := parser.CustomResourceDefinitions[groupKind]
 		addAttribution(&crdRaw)
 
-		versionedCRDs := make([]interface{}, len(crdVersions))
-		for i, ver := range crdVersions {
-			conv, err := AsVersion(crdRaw, schema.GroupVersion{Group: apiext.SchemeGroupVersion.Group, Version: ver})
-			if err != nil {
-				return err
-			}
-			versionedCRDs[i] = conv
+		var removed []string
+		var untrimmedSize int
+		if target != nil {
+			crdRaw = *crdRaw.DeepCopy() // don't mutate the parser's copy
+			removed = StripUnsupportedFeatures(&crdRaw, *target)
 		}
 
-		if g.TrivialVersions {
-			for i, crd := range versionedCRDs {
-				if crdVersions[i] == "v1beta1" {
-					toTrivialVersions(crd.(*apiextlegacy.CustomResourceDefinition))
+		if g.MaxSize != nil {
+			sizeOf := func(crd *apiext.CustomResourceDefinition) (int, error) {
+				versioned, err := g.toVersions(*crd, crdVersions)
+				if err != nil {
+					return 0, err
 				}
+				return maxSerializedSize(versioned)
+			}
+			origSize, err := sizeOf(&crdRaw)
+			if err != nil {
+				return nil, nil, err
+			}
+			crdRaw = *crdRaw.DeepCopy() // don't mutate the parser's copy
+			size, err := TrimDescriptionsToFit(&crdRaw, *g.MaxSize, sizeOf)
+			if err != nil {
+				return nil, nil, err
+			}
+			if size != origSize {
+				untrimmedSize = origSize
 			}
 		}
 
-		// *If* we're only generating v1beta1 CRDs, default to `preserveUnknownFields: (unset)`
-		// for compatibility purposes.  In any other case, default to false, since that's
-		// the sensible default and is required for v1.
-		v1beta1Only := len(crdVersions) == 1 && crdVersions[0] == "v1beta1"
-		switch {
-		case (g.PreserveUnknownFields == nil || *g.PreserveUnknownFields) && v1beta1Only:
-			crd := versionedCRDs[0].(*apiextlegacy.CustomResourceDefinition)
-			crd.Spec.PreserveUnknownFields = nil
-		case g.PreserveUnknownFields == nil, g.PreserveUnknownFields != nil && !*g.PreserveUnknownFields:
-			// it'll be false here (coming from v1) -- leave it as such
-		default:
-			return fmt.Errorf("you may only set PreserveUnknownFields to true with v1beta1 CRDs")
+		versionedCRDs, err := g.toVersions(crdRaw, crdVersions)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		for i, crd := range versionedCRDs {
-			// defaults are not allowed to be specified in v1beta1 CRDs, so strip them
-			// before writing to a file
-			if crdVersions[i] == "v1beta1" {
-				removeDefaultsFromSchemas(crd.(*apiextlegacy.CustomResourceDefinition))
+		if g.Report != "" || len(removed) > 0 {
+			size, err := maxSerializedSize(versionedCRDs)
+			if err != nil {
+				return nil, nil, err
+			}
+			report, err := kindReport(parser, groupKind, &crdRaw, size)
+			if err != nil {
+				return nil, nil, err
 			}
+			report.FeatureSet = set.name
+			report.UntrimmedSize = untrimmedSize
+			report.RemovedFeatures = removed
+			reports = append(reports, report)
+		}
+
+		for i, crd := range versionedCRDs {
 			var fileName string
 			if i == 0 {
-				fileName = fmt.Sprintf("%s_%s.yaml", crdRaw.Spec.Group, crdRaw.Spec.Names.Plural)
+				fileName = fmt.Sprintf("%s_%s%s.yaml", crdRaw.Spec.Group, crdRaw.Spec.Names.Plural, set.suffix())
 			} else {
-				fileName = fmt.Sprintf("%s_%s.%s.yaml", crdRaw.Spec.Group, crdRaw.Spec.Names.Plural, crdVersions[i])
+				fileName = fmt.Sprintf("%s_%s%s.%s.yaml", crdRaw.Spec.Group, crdRaw.Spec.Names.Plural, set.suffix(), crdVersions[i])
 			}
-			if err := ctx.WriteYAML(fileName, crd); err != nil {
-				return err
+			files = append(files, crdFile{name: fileName, obj: crd})
+		}
+	}
+
+	return files, reports, nil
+}
+
+// featureSet is a named set of enabled feature gates.
+type featureSet struct {
+	name string
+	// gates are the enabled gates, or nil if gating is disabled.
+	gates map[string]bool
+}
+
+// suffix returns the suffix for the names of files generated for this feature set.
+func (s featureSet) suffix() string {
+	if s.name == "" {
+		return ""
+	}
+	return "-" + s.name
+}
+
+// parseFeatureSets parses feature sets of the form `Name` or `Name=GateA+GateB`.
+func parseFeatureSets(rawSets []string) ([]featureSet, error) {
+	sets := make([]featureSet, 0, len(rawSets))
+	seen := make(map[string]bool)
+	for _, rawSet := range rawSets {
+		name, rawGates := rawSet, ""
+		if eq := strings.Index(rawSet, "="); eq >= 0 {
+			name, rawGates = rawSet[:eq], rawSet[eq+1:]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("feature set %q must have a name", rawSet)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("feature set %q specified more than once", name)
+		}
+		seen[name] = true
+
+		set := featureSet{name: name, gates: make(map[string]bool)}
+		if rawGates != "" {
+			for _, gate := range strings.Split(rawGates, "+") {
+				set.gates[gate] = true
 			}
 		}
+		sets = append(sets, set)
 	}
+	return sets, nil
+}
 
-	return nil
+// toVersions converts the given CRD to each of the given CRD versions,
+// applying the per-version options from this Generator.
+func (g Generator) toVersions(crdRaw apiext.CustomResourceDefinition, crdVersions []string) ([]interface{}, error) {
+	versionedCRDs := make([]interface{}, len(crdVersions))
+	for i, ver := range crdVersions {
+		conv, err := AsVersion(crdRaw, schema.GroupVersion{Group: apiext.SchemeGroupVersion.Group, Version: ver})
+		if err != nil {
+			return nil, err
+		}
+		versionedCRDs[i] = conv
+	}
+
+	if g.TrivialVersions {
+		for i, crd := range versionedCRDs {
+			if crdVersions[i] == "v1beta1" {
+				toTrivialVersions(crd.(*apiextlegacy.CustomResourceDefinition))
+			}
+		}
+	}
+
+	// *If* we're only generating v1beta1 CRDs, default to `preserveUnknownFields: (unset)`
+	// for compatibility purposes.  In any other case, default to false, since that's
+	// the sensible default and is required for v1.
+	v1beta1Only := len(crdVersions) == 1 && crdVersions[0] == "v1beta1"
+	switch {
+	case (g.PreserveUnknownFields == nil || *g.PreserveUnknownFields) && v1beta1Only:
+		crd := versionedCRDs[0].(*apiextlegacy.CustomResourceDefinition)
+		crd.Spec.PreserveUnknownFields = nil
+	case g.PreserveUnknownFields == nil, g.PreserveUnknownFields != nil && !*g.PreserveUnknownFields:
+		// it'll be false here (coming from v1) -- leave it as such
+	default:
+		return nil, fmt.Errorf("you may only set PreserveUnknownFields to true with v1beta1 CRDs")
+	}
+
+	// defaults are not allowed to be specified in v1beta1 CRDs, so strip them
+	// before writing to a file
+	for i, crd := range versionedCRDs {
+		if crdVersions[i] == "v1beta1" {
+			removeDefaultsFromSchemas(crd.(*apiextlegacy.CustomResourceDefinition))
+		}
+	}
+
+	return versionedCRDs, nil
+}
+
+// maxSerializedSize returns the size of the largest of the given objects
+// when serialized as JSON.
+func maxSerializedSize(objs []interface{}) (int, error) {
+	maxSize := 0
+	for _, obj := range objs {
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return 0, err
+		}
+		if len(raw) > maxSize {
+			maxSize = len(raw)
+		}
+	}
+	return maxSize, nil
 }
 
 // removeDefaultsFromSchemas will remove all instances of default values being
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// InferredListType describes a list type that was inferred for a field
+// lacking an explicit listType marker.
+type InferredListType struct {
+	// Type is the fully qualified name of the type containing the field.
+	Type string `json:"type"`
+	// Field is the JSON name of the field.
+	Field string `json:"field"`
+	// ListType is the inferred list type.
+	ListType string `json:"listType"`
+	// MapKeys are the inferred keys, for associative lists.
+	MapKeys []string `json:"mapKeys,omitempty"`
+	// Source describes what the list type was inferred from.
+	Source string `json:"source"`
+}
+
+// inferListType infers the list type of the given (array) field, if it
+// doesn't have one already, recording what was inferred.
+//
+// Lists of scalars are atomic.  Lists of objects are associative if the
+// field has a patchMergeKey struct tag, or the item type has listMapKey
+// markers, as long as the resulting keys are valid.  Other lists are left
+// alone.
+func inferListType(ctx *schemaContext, field markers.FieldInfo, fieldName string, props *apiext.JSONSchemaProps) {
+	if props.Type != "array" || props.XListType != nil || props.Items == nil || props.Items.Schema == nil {
+		return
+	}
+	items, itemsPkg := ctx.resolveSchema(*props.Items.Schema, ctx.pkg)
+	if items == nil {
+		return
+	}
+
+	var listType, source string
+	var keys []string
+	switch {
+	case isScalarSchema(items):
+		listType, source = "atomic", "scalar items"
+	case items.Type == "object":
+		keys, source = ctx.itemMapKeys(field, props.Items.Schema)
+		if len(keys) == 0 {
+			return
+		}
+
+		itemProps := make(map[string]apiext.JSONSchemaProps)
+		required := make(map[string]bool)
+		ctx.collectProperties(items, itemsPkg, itemProps, required)
+		for _, key := range keys {
+			if err := ctx.checkMapKey(key, itemProps, required, itemsPkg); err != nil {
+				// not usable as a key, so don't guess
+				return
+			}
+		}
+		listType = "map"
+	default:
+		return
+	}
+
+	props.XListType = &listType
+	props.XListMapKeys = keys
+	*ctx.inferredListTypes = append(*ctx.inferredListTypes, InferredListType{
+		Field:    fieldName,
+		ListType: listType,
+		MapKeys:  keys,
+		Source:   source,
+	})
+}
+
+// itemMapKeys returns the merge keys for a list field with the given item
+// schema, and where they came from, if any.
+func (c *schemaContext) itemMapKeys(field markers.FieldInfo, items *apiext.JSONSchemaProps) ([]string, string) {
+	if key := field.Tag.Get("patchMergeKey"); key != "" {
+		return []string{key}, "patchMergeKey tag"
+	}
+
+	if items.Ref == nil {
+		return nil, ""
+	}
+	ident, err := identFromRef(*items.Ref, c.pkg)
+	if err != nil || ident.Package == nil {
+		return nil, ""
+	}
+	info := c.schemaRequester.LookupType(ident.Package, ident.Name)
+	if info == nil {
+		return nil, ""
+	}
+	var keys []string
+	for _, key := range info.Markers["listMapKey"] {
+		keys = append(keys, string(key.(crdmarkers.ItemListMapKey)))
+	}
+	if len(keys) == 0 {
+		return nil, ""
+	}
+	return keys, "listMapKey markers on " + ident.Name
+}
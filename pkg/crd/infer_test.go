@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var _ = Describe("List type inference", func() {
+	var (
+		pkg     *loader.Package
+		cleanup func()
+		parser  *crd.Parser
+	)
+
+	BeforeEach(func() {
+		pkg, cleanup = loadFakePackage("sigs.k8s.io/controller-tools/pkg/crd/infertest", map[string]interface{}{
+			"types.go": `
+				package infertest
+
+				type Tagged struct {
+					Name string ` + "`json:\"name\"`" + `
+				}
+
+				// +listMapKey=kind
+				// +listMapKey=name
+				type Marked struct {
+					Kind string ` + "`json:\"kind\"`" + `
+					Name string ` + "`json:\"name\"`" + `
+				}
+
+				// +listMapKey=name
+				type OptionalKey struct {
+					Name string ` + "`json:\"name,omitempty\"`" + `
+				}
+
+				type Lists struct {
+					Scalars []string ` + "`json:\"scalars\"`" + `
+
+					// +listType=set
+					Explicit []string ` + "`json:\"explicit\"`" + `
+
+					Tagged []Tagged ` + "`json:\"tagged\" patchStrategy:\"merge\" patchMergeKey:\"name\"`" + `
+
+					Marked []Marked ` + "`json:\"marked\"`" + `
+
+					OptionalKey []OptionalKey ` + "`json:\"optionalKey\"`" + `
+
+					Unkeyed []Tagged ` + "`json:\"unkeyed\"`" + `
+				}
+			`,
+		})
+
+		reg := &markers.Registry{}
+		Expect(crdmarkers.Register(reg)).To(Succeed())
+		parser = &crd.Parser{
+			Collector:      &markers.Collector{Registry: reg},
+			Checker:        &loader.TypeChecker{},
+			InferListTypes: true,
+		}
+		parser.NeedPackage(pkg)
+	})
+
+	AfterEach(func() {
+		cleanup()
+	})
+
+	listsSchema := func() apiext.JSONSchemaProps {
+		typ := crd.TypeIdent{Package: pkg, Name: "Lists"}
+		parser.NeedSchemaFor(typ)
+		Expect(pkg.Errors).To(BeEmpty())
+		return parser.Schemata[typ]
+	}
+
+	It("should infer atomic lists for lists of scalars", func() {
+		prop := listsSchema().Properties["scalars"]
+		Expect(prop.XListType).NotTo(BeNil())
+		Expect(*prop.XListType).To(Equal("atomic"))
+	})
+
+	It("should leave explicit list types alone", func() {
+		prop := listsSchema().Properties["explicit"]
+		Expect(prop.XListType).NotTo(BeNil())
+		Expect(*prop.XListType).To(Equal("set"))
+	})
+
+	It("should infer associative lists from patchMergeKey tags", func() {
+		prop := listsSchema().Properties["tagged"]
+		Expect(prop.XListType).NotTo(BeNil())
+		Expect(*prop.XListType).To(Equal("map"))
+		Expect(prop.XListMapKeys).To(Equal([]string{"name"}))
+	})
+
+	It("should infer associative lists from listMapKey markers on the item type", func() {
+		prop := listsSchema().Properties["marked"]
+		Expect(prop.XListType).NotTo(BeNil())
+		Expect(*prop.XListType).To(Equal("map"))
+		Expect(prop.XListMapKeys).To(Equal([]string{"kind", "name"}))
+	})
+
+	It("should not infer associative lists with optional keys", func() {
+		prop := listsSchema().Properties["optionalKey"]
+		Expect(prop.XListType).To(BeNil())
+	})
+
+	It("should not infer anything for lists of objects without keys", func() {
+		prop := listsSchema().Properties["unkeyed"]
+		Expect(prop.XListType).To(BeNil())
+	})
+
+	It("should record everything that was inferred", func() {
+		listsSchema()
+		inferred := parser.InferredListTypes[crd.TypeIdent{Package: pkg, Name: "Lists"}]
+		Expect(inferred).To(ConsistOf(
+			crd.InferredListType{Type: "sigs.k8s.io/controller-tools/pkg/crd/infertest.Lists", Field: "scalars", ListType: "atomic", Source: "scalar items"},
+			crd.InferredListType{Type: "sigs.k8s.io/controller-tools/pkg/crd/infertest.Lists", Field: "tagged", ListType: "map", MapKeys: []string{"name"}, Source: "patchMergeKey tag"},
+			crd.InferredListType{Type: "sigs.k8s.io/controller-tools/pkg/crd/infertest.Lists", Field: "marked", ListType: "map", MapKeys: []string{"kind", "name"}, Source: "listMapKey markers on Marked"},
+		))
+	})
+})
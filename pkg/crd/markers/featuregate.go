@@ -0,0 +1,217 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// FeatureGateMarkers lists markers that include parts of a schema only when
+// some feature gate is enabled (see the crd generator's featureSets option).
+var FeatureGateMarkers = []*definitionWithHelp{
+	must(markers.MakeDefinition("kubebuilder:featureGate", markers.DescribesField, FeatureGate(""))).
+		WithHelp(FeatureGate("").Help()),
+	must(markers.MakeDefinition("kubebuilder:validation:FeatureGatedEnum", markers.DescribesField, FeatureGatedEnum{})).
+		WithHelp(FeatureGatedEnum{}.Help()),
+	must(markers.MakeDefinition("kubebuilder:validation:FeatureGatedEnum", markers.DescribesType, FeatureGatedEnum{})).
+		WithHelp(FeatureGatedEnum{}.Help()),
+	must(markers.MakeDefinition("kubebuilder:validation:FeatureGated", markers.DescribesField, FeatureGated{})).
+		WithHelp(FeatureGated{}.Help()),
+	must(markers.MakeDefinition("kubebuilder:validation:FeatureGated", markers.DescribesType, FeatureGated{})).
+		WithHelp(FeatureGated{}.Help()),
+}
+
+func init() {
+	AllDefinitions = append(AllDefinitions, FeatureGateMarkers...)
+}
+
+// FeatureGatedMarker is implemented by schema markers that should only be
+// applied in CRDs for feature sets that enable a given feature gate.
+//
+// The FeatureGated and FeatureGatedEnum markers implement it, and custom
+// validation markers can opt into gating the same way.  Markers that don't
+// implement it are always applied (unless the whole field is gated with the
+// featureGate marker).
+type FeatureGatedMarker interface {
+	// GatedBy returns the feature gate required to apply this marker.
+	GatedBy() string
+}
+
+// +controllertools:marker:generateHelp:category="CRD feature gates"
+
+// FeatureGate includes this field only in CRDs for feature sets that enable
+// the given feature gate.
+//
+// It may be repeated, in which case all of the given gates must be enabled.
+// If no feature sets are configured, all fields are included.
+//
+// To gate validations instead of the whole field, use FeatureGated (or
+// FeatureGatedEnum, for enum values).
+type FeatureGate string
+
+// +controllertools:marker:generateHelp:category="CRD feature gates"
+
+// FeatureGatedEnum adds the given values to the allowed values of this
+// (scalar) field only in CRDs for feature sets that enable the given feature
+// gate.
+//
+// The values that are always allowed must be listed with the regular Enum
+// marker on the same field or type, so that the field stays restricted to
+// them when the gate is disabled.
+type FeatureGatedEnum struct {
+	// FeatureGate is the feature gate that enables these values.
+	FeatureGate string
+	// Values are the additional allowed values.
+	Values []interface{}
+}
+
+func (m FeatureGatedEnum) GatedBy() string {
+	return m.FeatureGate
+}
+
+// ApplyLast makes sure the values are added after the ones from the Enum
+// marker, since that replaces whatever values were there.
+func (m FeatureGatedEnum) ApplyLast() {}
+
+func (m FeatureGatedEnum) ApplyToSchema(schema *apiext.JSONSchemaProps) error {
+	if len(schema.Enum) == 0 {
+		return fmt.Errorf("FeatureGatedEnum requires the Enum marker for the values allowed regardless of feature gates")
+	}
+	for _, val := range m.Values {
+		valMarshalled, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		schema.Enum = append(schema.Enum, apiext.JSON{Raw: valMarshalled})
+	}
+	return nil
+}
+
+// +controllertools:marker:generateHelp:category="CRD feature gates"
+
+// FeatureGated applies the given validations to this field or type only in
+// CRDs for feature sets that enable the given feature gate.
+//
+// The validations are the same as their regular markers, e.g.
+// `+kubebuilder:validation:FeatureGated:featureGate=Preview,maxLength=10`
+// only limits the length of the field when Preview is enabled.  When they're
+// combined with the regular markers, the gated validations take precedence.
+// Enum values are gated with FeatureGatedEnum instead.
+type FeatureGated struct {
+	// FeatureGate is the feature gate that enables these validations.
+	FeatureGate string
+
+	// Maximum is the gated form of the Maximum marker.
+	Maximum *int `marker:",optional"`
+	// Minimum is the gated form of the Minimum marker.
+	Minimum *int `marker:",optional"`
+	// ExclusiveMaximum is the gated form of the ExclusiveMaximum marker.
+	ExclusiveMaximum *bool `marker:",optional"`
+	// ExclusiveMinimum is the gated form of the ExclusiveMinimum marker.
+	ExclusiveMinimum *bool `marker:",optional"`
+	// MultipleOf is the gated form of the MultipleOf marker.
+	MultipleOf *int `marker:",optional"`
+	// MinProperties is the gated form of the MinProperties marker.
+	MinProperties *int `marker:",optional"`
+	// MaxProperties is the gated form of the MaxProperties marker.
+	MaxProperties *int `marker:",optional"`
+	// MaxLength is the gated form of the MaxLength marker.
+	MaxLength *int `marker:",optional"`
+	// MinLength is the gated form of the MinLength marker.
+	MinLength *int `marker:",optional"`
+	// Pattern is the gated form of the Pattern marker.
+	Pattern *string `marker:",optional"`
+	// MaxItems is the gated form of the MaxItems marker.
+	MaxItems *int `marker:",optional"`
+	// MinItems is the gated form of the MinItems marker.
+	MinItems *int `marker:",optional"`
+	// UniqueItems is the gated form of the UniqueItems marker.
+	UniqueItems *bool `marker:",optional"`
+	// Format is the gated form of the Format marker.
+	Format *string `marker:",optional"`
+}
+
+func (m FeatureGated) GatedBy() string {
+	return m.FeatureGate
+}
+
+// ApplyLast makes sure the gated validations take precedence over the
+// regular ones.
+func (m FeatureGated) ApplyLast() {}
+
+func (m FeatureGated) ApplyToSchema(schema *apiext.JSONSchemaProps) error {
+	var validations []interface {
+		ApplyToSchema(*apiext.JSONSchemaProps) error
+	}
+	if m.Maximum != nil {
+		validations = append(validations, Maximum(*m.Maximum))
+	}
+	if m.Minimum != nil {
+		validations = append(validations, Minimum(*m.Minimum))
+	}
+	if m.ExclusiveMaximum != nil {
+		validations = append(validations, ExclusiveMaximum(*m.ExclusiveMaximum))
+	}
+	if m.ExclusiveMinimum != nil {
+		validations = append(validations, ExclusiveMinimum(*m.ExclusiveMinimum))
+	}
+	if m.MultipleOf != nil {
+		validations = append(validations, MultipleOf(*m.MultipleOf))
+	}
+	if m.MinProperties != nil {
+		validations = append(validations, MinProperties(*m.MinProperties))
+	}
+	if m.MaxProperties != nil {
+		validations = append(validations, MaxProperties(*m.MaxProperties))
+	}
+	if m.MaxLength != nil {
+		validations = append(validations, MaxLength(*m.MaxLength))
+	}
+	if m.MinLength != nil {
+		validations = append(validations, MinLength(*m.MinLength))
+	}
+	if m.Pattern != nil {
+		validations = append(validations, Pattern(*m.Pattern))
+	}
+	if m.MaxItems != nil {
+		validations = append(validations, MaxItems(*m.MaxItems))
+	}
+	if m.MinItems != nil {
+		validations = append(validations, MinItems(*m.MinItems))
+	}
+	if m.UniqueItems != nil {
+		validations = append(validations, UniqueItems(*m.UniqueItems))
+	}
+	if m.Format != nil {
+		validations = append(validations, Format(*m.Format))
+	}
+
+	if len(validations) == 0 {
+		return fmt.Errorf("FeatureGated needs at least one validation to gate")
+	}
+	for _, validation := range validations {
+		if err := validation.ApplyToSchema(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
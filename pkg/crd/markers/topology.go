@@ -28,6 +28,8 @@ import (
 var TopologyMarkers = []*definitionWithHelp{
 	must(markers.MakeDefinition("listMapKey", markers.DescribesField, ListMapKey(""))).
 		WithHelp(ListMapKey("").Help()),
+	must(markers.MakeDefinition("listMapKey", markers.DescribesType, ItemListMapKey(""))).
+		WithHelp(ItemListMapKey("").Help()),
 	must(markers.MakeDefinition("listType", markers.DescribesField, ListType(""))).
 		WithHelp(ListType("").Help()),
 	must(markers.MakeDefinition("mapType", markers.DescribesField, MapType(""))).
@@ -69,6 +71,16 @@ type ListMapKey string
 
 // +controllertools:marker:generateHelp:category="CRD processing"
 
+// ItemListMapKey specifies the keys of lists of this type.
+//
+// When list types are inferred (see the crd generator's inferListTypes
+// option), lists of this type without an explicit listType are treated as
+// associative lists keyed by the given fields.  It can be repeated if
+// multiple keys must be used, and has no effect otherwise.
+type ItemListMapKey string
+
+// +controllertools:marker:generateHelp:category="CRD processing"
+
 // MapType specifies the level of atomicity of the map;
 // i.e. whether each item in the map is independent of the others,
 // or all fields are treated as a single unit.
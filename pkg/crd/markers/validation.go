@@ -18,6 +18,7 @@ package markers
 
 import (
 	"fmt"
+	"strings"
 
 	"encoding/json"
 
@@ -26,6 +27,15 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
+const (
+	// ItemsMarkerPrefix prefixes copies of the ValidationMarkers that
+	// apply to the items of a list, instead of the list itself.
+	ItemsMarkerPrefix = "kubebuilder:validation:items:"
+	// ValuesMarkerPrefix prefixes copies of the ValidationMarkers that
+	// apply to the values of a map, instead of the map itself.
+	ValuesMarkerPrefix = "kubebuilder:validation:values:"
+)
+
 // ValidationMarkers lists all available markers that affect CRD schema generation,
 // except for the few that don't make sense as type-level markers (see FieldOnlyMarkers).
 // All markers start with `+kubebuilder:validation:`, and continue with their type name.
@@ -84,6 +94,22 @@ var FieldOnlyMarkers = []*definitionWithHelp{
 		WithHelp(XPreserveUnknownFields{}.Help()),
 	must(markers.MakeDefinition("kubebuilder:validation:EmbeddedResource", markers.DescribesField, XEmbeddedResource{})).
 		WithHelp(XEmbeddedResource{}.Help()),
+
+	must(markers.MakeDefinition("union", markers.DescribesField, UnionMember{})).
+		WithHelp(UnionMember{}.Help()),
+	must(markers.MakeDefinition("unionDiscriminator", markers.DescribesField, UnionDiscriminator{})).
+		WithHelp(UnionDiscriminator{}.Help()),
+}
+
+// TypeOnlyMarkers list type-specific validation markers (i.e. those markers that don't make
+// sense on a field, and thus aren't in ValidationMarkers).
+var TypeOnlyMarkers = []*definitionWithHelp{
+	must(markers.MakeDefinition("kubebuilder:validation:OneOf", markers.DescribesType, OneOf(nil))).
+		WithHelp(OneOf(nil).Help()),
+	must(markers.MakeDefinition("kubebuilder:validation:ExactlyOneOf", markers.DescribesType, ExactlyOneOf(nil))).
+		WithHelp(ExactlyOneOf(nil).Help()),
+	must(markers.MakeDefinition("kubebuilder:validation:AtMostOneOf", markers.DescribesType, AtMostOneOf(nil))).
+		WithHelp(AtMostOneOf(nil).Help()),
 }
 
 func init() {
@@ -100,7 +126,30 @@ func init() {
 		AllDefinitions = append(AllDefinitions, &typDef)
 	}
 
+	// copies for list items & map values, so that validating those doesn't
+	// require declaring a named type
+	for _, prefix := range []string{ItemsMarkerPrefix, ValuesMarkerPrefix} {
+		for _, def := range ValidationMarkers {
+			help := *def.Help
+			if prefix == ItemsMarkerPrefix {
+				help.Summary = "(for each item of this list) " + help.Summary
+			} else {
+				help.Summary = "(for each value of this map) " + help.Summary
+			}
+			for _, target := range []markers.TargetType{markers.DescribesField, markers.DescribesType} {
+				newDef := *def.Definition
+				newDef.Name = prefix + strings.TrimPrefix(def.Name, "kubebuilder:validation:")
+				newDef.Target = target
+				AllDefinitions = append(AllDefinitions, &definitionWithHelp{
+					Definition: &newDef,
+					Help:       &help,
+				})
+			}
+		}
+	}
+
 	AllDefinitions = append(AllDefinitions, FieldOnlyMarkers...)
+	AllDefinitions = append(AllDefinitions, TypeOnlyMarkers...)
 }
 
 // +controllertools:marker:generateHelp:category="CRD validation"
@@ -212,6 +261,42 @@ type XPreserveUnknownFields struct{}
 // field, yet it is possible. This can be combined with PreserveUnknownFields.
 type XEmbeddedResource struct{}
 
+// +controllertools:marker:generateHelp:category="CRD validation"
+// UnionMember marks this field as a member of its struct's union.
+//
+// At most one of the members of a union may be set, and each member must be
+// optional.  If the struct has a union discriminator, a member may only be
+// set if the discriminator names it.
+type UnionMember struct{}
+
+// +controllertools:marker:generateHelp:category="CRD validation"
+// UnionDiscriminator marks this field as the discriminator of its struct's union.
+//
+// The discriminator names the (Go) field of the union member that's set, if
+// any.  A struct may only have one discriminator, and it requires the struct
+// to have union members.
+type UnionDiscriminator struct{}
+
+// +controllertools:marker:generateHelp:category="CRD validation"
+// OneOf specifies that exactly one of the given fields of this type must be set.
+//
+// It's equivalent to ExactlyOneOf, and named after the JSON schema
+// construct it's validated with.  The fields are given by their JSON
+// names, and must be optional.
+type OneOf []string
+
+// +controllertools:marker:generateHelp:category="CRD validation"
+// ExactlyOneOf specifies that exactly one of the given fields of this type must be set.
+//
+// The fields are given by their JSON names, and must be optional.
+type ExactlyOneOf []string
+
+// +controllertools:marker:generateHelp:category="CRD validation"
+// AtMostOneOf specifies that at most one of the given fields of this type may be set.
+//
+// The fields are given by their JSON names, and must be optional.
+type AtMostOneOf []string
+
 func (m Maximum) ApplyToSchema(schema *apiext.JSONSchemaProps) error {
 	if schema.Type != "integer" {
 		return fmt.Errorf("must apply maximum to an integer")
@@ -376,3 +461,82 @@ func (m XEmbeddedResource) ApplyToSchema(schema *apiext.JSONSchemaProps) error {
 	schema.XEmbeddedResource = true
 	return nil
 }
+
+func (m OneOf) ApplyToSchema(schema *apiext.JSONSchemaProps) error {
+	return ExactlyOneOf(m).ApplyToSchema(schema)
+}
+
+func (m ExactlyOneOf) ApplyToSchema(schema *apiext.JSONSchemaProps) error {
+	if err := checkOneOfMembers(schema, m); err != nil {
+		return err
+	}
+	oneOf := make([]apiext.JSONSchemaProps, len(m))
+	for i, member := range m {
+		oneOf[i] = apiext.JSONSchemaProps{Required: []string{member}}
+	}
+	if schema.OneOf == nil {
+		schema.OneOf = oneOf
+	} else {
+		// flattening takes care of combining these
+		schema.AllOf = append(schema.AllOf, apiext.JSONSchemaProps{OneOf: oneOf})
+	}
+	return nil
+}
+
+func (m AtMostOneOf) ApplyToSchema(schema *apiext.JSONSchemaProps) error {
+	if err := checkOneOfMembers(schema, m); err != nil {
+		return err
+	}
+	AddForbiddenCombinations(schema, MemberPairs(m)...)
+	return nil
+}
+
+// checkOneOfMembers checks that the given members of a one-of are optional
+// fields of the given (struct) schema.
+func checkOneOfMembers(schema *apiext.JSONSchemaProps, members []string) error {
+	if schema.Type != "object" {
+		return fmt.Errorf("must apply one-of markers to a struct")
+	}
+	if len(members) < 2 {
+		return fmt.Errorf("one-of markers must list at least two fields")
+	}
+	for _, member := range members {
+		// fields from embedded structs aren't known until flattening
+		if _, known := schema.Properties[member]; !known && len(schema.AllOf) == 0 {
+			return fmt.Errorf("%q is not a field of this type", member)
+		}
+		for _, req := range schema.Required {
+			if req == member {
+				return fmt.Errorf("field %q must be optional to be part of a one-of", member)
+			}
+		}
+	}
+	return nil
+}
+
+// MemberPairs returns a schema matching each pair of the given fields being
+// set together.
+func MemberPairs(members []string) []apiext.JSONSchemaProps {
+	var pairs []apiext.JSONSchemaProps
+	for i, first := range members {
+		for _, second := range members[i+1:] {
+			pairs = append(pairs, apiext.JSONSchemaProps{Required: []string{first, second}})
+		}
+	}
+	return pairs
+}
+
+// AddForbiddenCombinations adds a `not: {anyOf: ...}` constraint to the given
+// schema, forbidding it from matching any of the given schemata.
+func AddForbiddenCombinations(schema *apiext.JSONSchemaProps, combinations ...apiext.JSONSchemaProps) {
+	if len(combinations) == 0 {
+		return
+	}
+	not := &apiext.JSONSchemaProps{AnyOf: combinations}
+	if schema.Not == nil {
+		schema.Not = not
+	} else {
+		// flattening takes care of combining these
+		schema.AllOf = append(schema.AllOf, apiext.JSONSchemaProps{Not: not})
+	}
+}
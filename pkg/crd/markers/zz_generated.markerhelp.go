@@ -24,6 +24,17 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
+func (AtMostOneOf) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD validation",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "specifies that at most one of the given fields of this type may be set. ",
+			Details: "The fields are given by their JSON names, and must be optional.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
 func (Default) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "CRD validation",
@@ -51,6 +62,17 @@ func (Enum) Help() *markers.DefinitionHelp {
 	}
 }
 
+func (ExactlyOneOf) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD validation",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "specifies that exactly one of the given fields of this type must be set. ",
+			Details: "The fields are given by their JSON names, and must be optional.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
 func (ExclusiveMaximum) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "CRD validation",
@@ -73,6 +95,109 @@ func (ExclusiveMinimum) Help() *markers.DefinitionHelp {
 	}
 }
 
+func (FeatureGate) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD feature gates",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "includes this field only in CRDs for feature sets that enable the given feature gate. ",
+			Details: "It may be repeated, in which case all of the given gates must be enabled. If no feature sets are configured, all fields are included. \n To gate validations instead of the whole field, use FeatureGated (or FeatureGatedEnum, for enum values).",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
+func (FeatureGated) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD feature gates",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "applies the given validations to this field or type only in CRDs for feature sets that enable the given feature gate. ",
+			Details: "The validations are the same as their regular markers, e.g. `+kubebuilder:validation:FeatureGated:featureGate=Preview,maxLength=10` only limits the length of the field when Preview is enabled.  When they're combined with the regular markers, the gated validations take precedence. Enum values are gated with FeatureGatedEnum instead.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"FeatureGate": markers.DetailedHelp{
+				Summary: "is the feature gate that enables these validations.",
+				Details: "",
+			},
+			"Maximum": markers.DetailedHelp{
+				Summary: "is the gated form of the Maximum marker.",
+				Details: "",
+			},
+			"Minimum": markers.DetailedHelp{
+				Summary: "is the gated form of the Minimum marker.",
+				Details: "",
+			},
+			"ExclusiveMaximum": markers.DetailedHelp{
+				Summary: "is the gated form of the ExclusiveMaximum marker.",
+				Details: "",
+			},
+			"ExclusiveMinimum": markers.DetailedHelp{
+				Summary: "is the gated form of the ExclusiveMinimum marker.",
+				Details: "",
+			},
+			"MultipleOf": markers.DetailedHelp{
+				Summary: "is the gated form of the MultipleOf marker.",
+				Details: "",
+			},
+			"MinProperties": markers.DetailedHelp{
+				Summary: "is the gated form of the MinProperties marker.",
+				Details: "",
+			},
+			"MaxProperties": markers.DetailedHelp{
+				Summary: "is the gated form of the MaxProperties marker.",
+				Details: "",
+			},
+			"MaxLength": markers.DetailedHelp{
+				Summary: "is the gated form of the MaxLength marker.",
+				Details: "",
+			},
+			"MinLength": markers.DetailedHelp{
+				Summary: "is the gated form of the MinLength marker.",
+				Details: "",
+			},
+			"Pattern": markers.DetailedHelp{
+				Summary: "is the gated form of the Pattern marker.",
+				Details: "",
+			},
+			"MaxItems": markers.DetailedHelp{
+				Summary: "is the gated form of the MaxItems marker.",
+				Details: "",
+			},
+			"MinItems": markers.DetailedHelp{
+				Summary: "is the gated form of the MinItems marker.",
+				Details: "",
+			},
+			"UniqueItems": markers.DetailedHelp{
+				Summary: "is the gated form of the UniqueItems marker.",
+				Details: "",
+			},
+			"Format": markers.DetailedHelp{
+				Summary: "is the gated form of the Format marker.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (FeatureGatedEnum) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD feature gates",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "adds the given values to the allowed values of this (scalar) field only in CRDs for feature sets that enable the given feature gate. ",
+			Details: "The values that are always allowed must be listed with the regular Enum marker on the same field or type, so that the field stays restricted to them when the gate is disabled.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"FeatureGate": markers.DetailedHelp{
+				Summary: "is the feature gate that enables these values.",
+				Details: "",
+			},
+			"Values": markers.DetailedHelp{
+				Summary: "are the additional allowed values.",
+				Details: "",
+			},
+		},
+	}
+}
+
 func (Format) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "CRD validation",
@@ -84,6 +209,17 @@ func (Format) Help() *markers.DefinitionHelp {
 	}
 }
 
+func (ItemListMapKey) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD processing",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "specifies the keys of lists of this type. ",
+			Details: "When list types are inferred (see the crd generator's inferListTypes option), lists of this type without an explicit listType are treated as associative lists keyed by the given fields.  It can be repeated if multiple keys must be used, and has no effect otherwise.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
 func (ListMapKey) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "CRD processing",
@@ -198,7 +334,7 @@ func (Minimum) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "CRD validation",
 		DetailedHelp: markers.DetailedHelp{
-			Summary: "specifies the minimum numeric value that this field can have.",
+			Summary: "specifies the minimum numeric value that this field can have. Negative integers are supported.",
 			Details: "",
 		},
 		FieldHelp: map[string]markers.DetailedHelp{},
@@ -227,6 +363,17 @@ func (Nullable) Help() *markers.DefinitionHelp {
 	}
 }
 
+func (OneOf) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD validation",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "specifies that exactly one of the given fields of this type must be set. ",
+			Details: "It's equivalent to ExactlyOneOf, and named after the JSON schema construct it's validated with.  The fields are given by their JSON names, and must be optional.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
 func (Pattern) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "CRD validation",
@@ -385,6 +532,28 @@ func (Type) Help() *markers.DefinitionHelp {
 	}
 }
 
+func (UnionDiscriminator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD validation",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "marks this field as the discriminator of its struct's union. ",
+			Details: "The discriminator names the (Go) field of the union member that's set, if any.  A struct may only have one discriminator, and it requires the struct to have union members.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
+func (UnionMember) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "CRD validation",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "marks this field as a member of its struct's union. ",
+			Details: "At most one of the members of a union may be set, and each member must be optional.  If the struct has a union discriminator, a member may only be set if the discriminator names it.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
 func (UniqueItems) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "CRD validation",
@@ -0,0 +1,26 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migrate contains a generator for migrating existing, hand-written
+// legacy (apiextensions/v1beta1) CRD manifests to apiextensions/v1.
+//
+// The conversion itself is done by the upstream apiextensions conversion
+// machinery (via crd.FromLegacy), which takes care of moving global
+// schemata, subresources and printer columns onto each version.  On top of
+// that, the generator fixes up the bits that are valid in v1beta1 but not in
+// v1 (like missing schemata), and reports anything that behaves differently
+// after migration, so that it can be reviewed by hand.
+package migrate
@@ -0,0 +1,185 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	apiextlegacy "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var legacyAPIExtVersion = apiextlegacy.SchemeGroupVersion.String()
+
+// +controllertools:marker:generateHelp
+
+// Generator migrates existing legacy (v1beta1) CRD manifests to apiextensions/v1.
+//
+// Each YAML file in the given directory that contains legacy CRDs is written
+// out under the same name, with those CRDs converted to v1, and any other
+// objects in the file passed through.  Global schemata, subresources and
+// printer columns are moved onto each version, and preserveUnknownFields
+// is set to false.
+//
+// Anything that can't be converted losslessly (or behaves differently in
+// v1) is reported, but doesn't stop the migration: the issues are written to
+// the report, if one is requested, and otherwise fail generation once all
+// the manifests have been written.  Since the manifests are re-serialized,
+// comments and formatting aren't preserved.
+//
+// No Go types are needed for the migration, but controller-gen always loads
+// the packages given by `paths`, so run it from within a Go module.
+type Generator struct {
+	// ManifestsPath contains the CustomResourceDefinition YAML files to migrate.
+	ManifestsPath string `marker:"dir"`
+
+	// Report specifies the path of a Markdown report listing the issues
+	// found while migrating, relative to the output location of this generator.
+	//
+	// Left unspecified, any issues are returned as an error instead.
+	Report string `marker:",optional"`
+}
+
+func (Generator) RegisterMarkers(into *markers.Registry) error {
+	return nil
+}
+
+func (g Generator) Generate(ctx *genall.GenerationContext) error {
+	dirEntries, err := ioutil.ReadDir(g.ManifestsPath)
+	if err != nil {
+		return err
+	}
+
+	var issues []string
+	for _, fileInfo := range dirEntries {
+		ext := filepath.Ext(fileInfo.Name())
+		if fileInfo.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		rawContent, err := ctx.ReadFile(filepath.Join(g.ManifestsPath, fileInfo.Name()))
+		if err != nil {
+			return err
+		}
+		objs, migrated, err := migrateFile(rawContent, func(issue string) {
+			issues = append(issues, fmt.Sprintf("%s: %s", fileInfo.Name(), issue))
+		})
+		if err != nil {
+			return fmt.Errorf("unable to migrate %s: %w", fileInfo.Name(), err)
+		}
+		if !migrated {
+			continue
+		}
+
+		if err := ctx.WriteYAML(fileInfo.Name(), objs...); err != nil {
+			return err
+		}
+	}
+
+	if g.Report != "" {
+		return writeReport(ctx, g.Report, issues)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d issue(s) migrating CRDs (request a report to allow them):\n%s", len(issues), strings.Join(issues, "\n"))
+	}
+	return nil
+}
+
+// writeReport writes the given migration issues out to the given file, as Markdown.
+func writeReport(ctx *genall.GenerationContext, itemPath string, issues []string) error {
+	var buf strings.Builder
+	buf.WriteString("# CRD Migration Issues\n\n")
+	if len(issues) == 0 {
+		buf.WriteString("None.\n")
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(&buf, "- %s\n", issue)
+	}
+
+	out, err := ctx.Open(nil, itemPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.WriteString(out, buf.String())
+	return err
+}
+
+// migrateFile migrates the legacy CRDs in the given (possibly multi-document)
+// manifest, returning all the objects in the manifest in order, and whether
+// anything was migrated at all.  Issues are reported to the given callback.
+func migrateFile(rawContent []byte, report func(issue string)) ([]interface{}, bool, error) {
+	var objs []interface{}
+	migrated := false
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(rawContent)))
+	for {
+		rawDoc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if len(bytes.TrimSpace(rawDoc)) == 0 {
+			continue
+		}
+
+		var typeMeta metav1.TypeMeta
+		if err := yaml.Unmarshal(rawDoc, &typeMeta); err != nil {
+			return nil, false, err
+		}
+		if typeMeta.APIVersion != legacyAPIExtVersion || typeMeta.Kind != "CustomResourceDefinition" {
+			// pass everything else through
+			var obj map[string]interface{}
+			if err := yaml.Unmarshal(rawDoc, &obj); err != nil {
+				return nil, false, err
+			}
+			if obj != nil {
+				objs = append(objs, obj)
+			}
+			continue
+		}
+
+		var legacy apiextlegacy.CustomResourceDefinition
+		if err := yaml.UnmarshalStrict(rawDoc, &legacy); err != nil {
+			return nil, false, err
+		}
+		crd, issues, err := Migrate(legacy)
+		if err != nil {
+			return nil, false, fmt.Errorf("CRD %s: %w", legacy.Name, err)
+		}
+		for _, issue := range issues {
+			report(fmt.Sprintf("CRD %s: %s", legacy.Name, issue))
+		}
+		objs = append(objs, crd)
+		migrated = true
+	}
+
+	return objs, migrated, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/crd/migrate"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+const legacyManifest = `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  version: v1
+  validation:
+    openAPIV3Schema:
+      type: object
+`
+
+var _ = Describe("CRD Migration Generator", func() {
+	var manifestsDir, outputDir string
+	var ctx *genall.GenerationContext
+
+	BeforeEach(func() {
+		var err error
+		manifestsDir, err = ioutil.TempDir("", "controller-tools-test")
+		Expect(err).NotTo(HaveOccurred())
+		outputDir, err = ioutil.TempDir("", "controller-tools-test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(manifestsDir, "widgets.yaml"), []byte(legacyManifest), 0644)).To(Succeed())
+
+		ctx = &genall.GenerationContext{
+			InputRule:  genall.InputFromFileSystem,
+			OutputRule: genall.OutputToDirectory(outputDir),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(manifestsDir)).To(Succeed())
+		Expect(os.RemoveAll(outputDir)).To(Succeed())
+	})
+
+	It("should fail with the issues found if no report is requested", func() {
+		err := migrate.Generator{ManifestsPath: manifestsDir}.Generate(ctx)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("widgets.yaml: CRD widgets.example.com: spec.preserveUnknownFields:"))
+
+		By("checking that the manifest was migrated anyway")
+		Expect(filepath.Join(outputDir, "widgets.yaml")).To(BeAnExistingFile())
+	})
+
+	It("should write the issues found to the report, if requested", func() {
+		Expect(migrate.Generator{ManifestsPath: manifestsDir, Report: "issues.md"}.Generate(ctx)).To(Succeed())
+
+		report, err := ioutil.ReadFile(filepath.Join(outputDir, "issues.md"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(report)).To(HavePrefix("# CRD Migration Issues\n\n- widgets.yaml: CRD widgets.example.com: spec.preserveUnknownFields:"))
+		Expect(filepath.Join(outputDir, "widgets.yaml")).To(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"fmt"
+	"sort"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextlegacy "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+
+	crdgen "sigs.k8s.io/controller-tools/pkg/crd"
+)
+
+// Migrate converts the given legacy (v1beta1) CRD to v1, returning the
+// converted CRD along with a description of each construct that couldn't be
+// converted losslessly, or that behaves differently in v1.
+func Migrate(legacy apiextlegacy.CustomResourceDefinition) (*apiext.CustomResourceDefinition, []string, error) {
+	var issues []string
+
+	if legacy.Spec.PreserveUnknownFields == nil || *legacy.Spec.PreserveUnknownFields {
+		issues = append(issues, "spec.preserveUnknownFields: was true (the v1beta1 default), but v1 requires false, so unknown fields will now be pruned "+
+			"unless the schema sets x-kubernetes-preserve-unknown-fields")
+	}
+	hadConversion := legacy.Spec.Conversion != nil
+	if hadConversion && legacy.Spec.Conversion.Strategy == apiextlegacy.WebhookConverter && len(legacy.Spec.Conversion.ConversionReviewVersions) == 0 {
+		issues = append(issues, "spec.conversion.conversionReviewVersions: set to the v1beta1 default of [v1beta1], since v1 requires it to be explicit")
+	}
+
+	crd, err := crdgen.FromLegacy(legacy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	crd.Spec.PreserveUnknownFields = false
+	if !hadConversion {
+		// don't litter the output with the defaulted "None" strategy
+		crd.Spec.Conversion = nil
+	}
+	// status isn't part of the manifest, so clear out anything defaulting
+	// put there (see the CRD generator for why these are empty instead of nil)
+	crd.Status = apiext.CustomResourceDefinitionStatus{
+		Conditions:     []apiext.CustomResourceDefinitionCondition{},
+		StoredVersions: []string{},
+	}
+
+	for i, ver := range crd.Spec.Versions {
+		path := fmt.Sprintf("spec.versions[%s].schema", ver.Name)
+		if ver.Schema == nil || ver.Schema.OpenAPIV3Schema == nil {
+			preserve := true
+			crd.Spec.Versions[i].Schema = &apiext.CustomResourceValidation{
+				OpenAPIV3Schema: &apiext.JSONSchemaProps{
+					Type:                   "object",
+					XPreserveUnknownFields: &preserve,
+				},
+			}
+			issues = append(issues, path+": missing, which v1 doesn't allow, so it was replaced with a schema that preserves all fields")
+			continue
+		}
+		checkStructural(path+".openAPIV3Schema", ver.Schema.OpenAPIV3Schema, &issues)
+	}
+
+	return crd, issues, nil
+}
+
+// checkStructural reports the parts of the given schema that keep it from
+// being structural, which v1 requires (and v1beta1 merely recommends).
+//
+// This isn't a complete check (see the apiextensions validation for that),
+// but catches the common case of hand-written schemata leaving out types.
+func checkStructural(path string, schema *apiext.JSONSchemaProps, issues *[]string) {
+	if schema.Type == "" && !schema.XIntOrString && (schema.XPreserveUnknownFields == nil || !*schema.XPreserveUnknownFields) {
+		*issues = append(*issues, path+": has no type, which v1 requires (the schema must be structural)")
+	}
+
+	propNames := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		propNames = append(propNames, name)
+	}
+	sort.Strings(propNames)
+	for _, name := range propNames {
+		prop := schema.Properties[name]
+		checkStructural(path+".properties."+name, &prop, issues)
+	}
+	if schema.Items != nil && schema.Items.Schema != nil {
+		checkStructural(path+".items", schema.Items.Schema, issues)
+	}
+	if schema.Items != nil && len(schema.Items.JSONSchemas) > 0 {
+		*issues = append(*issues, path+".items: is a list of schemata, which v1 doesn't allow")
+	}
+	if schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+		checkStructural(path+".additionalProperties", schema.AdditionalProperties.Schema, issues)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextlegacy "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/controller-tools/pkg/crd/migrate"
+)
+
+var _ = Describe("CRD Migration", func() {
+	parseLegacy := func(raw string) apiextlegacy.CustomResourceDefinition {
+		var legacy apiextlegacy.CustomResourceDefinition
+		ExpectWithOffset(1, yaml.UnmarshalStrict([]byte(raw), &legacy)).To(Succeed())
+		return legacy
+	}
+
+	It("should lift the global schema, subresources and columns into each version", func() {
+		legacy := parseLegacy(`
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  version: v1
+  preserveUnknownFields: false
+  subresources:
+    status: {}
+  additionalPrinterColumns:
+  - name: Size
+    type: integer
+    JSONPath: .spec.size
+  validation:
+    openAPIV3Schema:
+      type: object
+      properties:
+        spec:
+          type: object
+          properties:
+            size:
+              type: integer
+`)
+		crd, issues, err := migrate.Migrate(legacy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(BeEmpty())
+
+		Expect(crd.APIVersion).To(Equal("apiextensions.k8s.io/v1"))
+		Expect(crd.Spec.PreserveUnknownFields).To(BeFalse())
+		Expect(crd.Spec.Conversion).To(BeNil())
+		Expect(crd.Spec.Versions).To(HaveLen(1))
+
+		ver := crd.Spec.Versions[0]
+		Expect(ver.Name).To(Equal("v1"))
+		Expect(ver.Served).To(BeTrue())
+		Expect(ver.Storage).To(BeTrue())
+		Expect(ver.Schema).NotTo(BeNil())
+		Expect(ver.Schema.OpenAPIV3Schema.Properties["spec"].Properties).To(HaveKey("size"))
+		Expect(ver.Subresources).NotTo(BeNil())
+		Expect(ver.Subresources.Status).NotTo(BeNil())
+		Expect(ver.AdditionalPrinterColumns).To(Equal([]apiext.CustomResourceColumnDefinition{
+			{Name: "Size", Type: "integer", JSONPath: ".spec.size"},
+		}))
+	})
+
+	It("should report constructs that don't convert losslessly", func() {
+		legacy := parseLegacy(`
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  conversion:
+    strategy: Webhook
+    webhookClientConfig:
+      url: https://example.com/convert
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            properties:
+              size:
+                type: integer
+  - name: v2
+    served: true
+    storage: false
+`)
+		crd, issues, err := migrate.Migrate(legacy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(HaveLen(4))
+		Expect(issues[0]).To(HavePrefix("spec.preserveUnknownFields:"))
+		Expect(issues[1]).To(HavePrefix("spec.conversion.conversionReviewVersions:"))
+		Expect(issues[2]).To(HavePrefix("spec.versions[v1].schema.openAPIV3Schema.properties.spec: has no type"))
+		Expect(issues[3]).To(HavePrefix("spec.versions[v2].schema: missing"))
+
+		By("checking that the missing bits were filled in")
+		Expect(crd.Spec.PreserveUnknownFields).To(BeFalse())
+		Expect(crd.Spec.Conversion).NotTo(BeNil())
+		Expect(crd.Spec.Conversion.Webhook).NotTo(BeNil())
+		Expect(crd.Spec.Conversion.Webhook.ConversionReviewVersions).To(Equal([]string{"v1beta1"}))
+		Expect(crd.Spec.Versions[1].Schema).NotTo(BeNil())
+		Expect(crd.Spec.Versions[1].Schema.OpenAPIV3Schema.Type).To(Equal("object"))
+		Expect(crd.Spec.Versions[1].Schema.OpenAPIV3Schema.XPreserveUnknownFields).NotTo(BeNil())
+		Expect(*crd.Spec.Versions[1].Schema.OpenAPIV3Schema.XPreserveUnknownFields).To(BeTrue())
+	})
+})
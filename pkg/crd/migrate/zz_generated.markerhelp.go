@@ -0,0 +1,45 @@
+// +build !ignore_autogenerated
+
+/*
+Copyright2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by helpgen. DO NOT EDIT.
+
+package migrate
+
+import (
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+func (Generator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "migrates existing legacy (v1beta1) CRD manifests to apiextensions/v1. ",
+			Details: "Each YAML file in the given directory that contains legacy CRDs is written out under the same name, with those CRDs converted to v1, and any other objects in the file passed through.  Global schemata, subresources and printer columns are moved onto each version, and preserveUnknownFields is set to false. \n Anything that can't be converted losslessly (or behaves differently in v1) is reported, but doesn't stop the migration: the issues are written to the report, if one is requested, and otherwise fail generation once all the manifests have been written.  Since the manifests are re-serialized, comments and formatting aren't preserved. \n No Go types are needed for the migration, but controller-gen always loads the packages given by `paths`, so run it from within a Go module.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"ManifestsPath": markers.DetailedHelp{
+				Summary: "contains the CustomResourceDefinition YAML files to migrate.",
+				Details: "",
+			},
+			"Report": markers.DetailedHelp{
+				Summary: "specifies the path of a Markdown report listing the issues found while migrating, relative to the output location of this generator. ",
+				Details: "Left unspecified, any issues are returned as an error instead.",
+			},
+		},
+	}
+}
@@ -86,6 +86,19 @@ type Parser struct {
 	//       because the implementation is too difficult/clunky to promote them to category 3.
 	// TODO: Should we have a more formal mechanism for putting "type patterns" in each of the above categories?
 	AllowDangerousTypes bool
+
+	// InferListTypes indicates that list types should be inferred for list
+	// fields without an explicit listType marker.  Inferred list types are
+	// recorded in InferredListTypes.
+	InferListTypes bool
+	// InferredListTypes contains the list types inferred for the fields
+	// of each type, if InferListTypes is set.
+	InferredListTypes map[TypeIdent][]InferredListType
+
+	// FeatureGates contains the enabled feature gates.  If it's non-nil,
+	// fields and validation gated by other feature gates are left out of
+	// the generated schemata.  Otherwise, everything is included.
+	FeatureGates map[string]bool
 }
 
 func (p *Parser) init() {
@@ -115,6 +128,9 @@ func (p *Parser) init() {
 	if p.FlattenedSchemata == nil {
 		p.FlattenedSchemata = make(map[TypeIdent]apiext.JSONSchemaProps)
 	}
+	if p.InferredListTypes == nil {
+		p.InferredListTypes = make(map[TypeIdent][]InferredListType)
+	}
 }
 
 // indexTypes loads all types in the package into Types.
@@ -176,6 +192,10 @@ func (p *Parser) NeedSchemaFor(typ TypeIdent) {
 	p.Schemata[typ] = apiext.JSONSchemaProps{}
 
 	schemaCtx := newSchemaContext(typ.Package, p, p.AllowDangerousTypes)
+	var inferred []InferredListType
+	schemaCtx.inferListTypes = p.InferListTypes
+	schemaCtx.featureGates = p.FeatureGates
+	schemaCtx.inferredListTypes = &inferred
 	ctxForInfo := schemaCtx.ForInfo(info)
 
 	pkgMarkers, err := markers.PackageMarkers(p.Collector, typ.Package)
@@ -187,6 +207,18 @@ func (p *Parser) NeedSchemaFor(typ TypeIdent) {
 	schema := infoToSchema(ctxForInfo)
 
 	p.Schemata[typ] = *schema
+	if len(inferred) > 0 {
+		for i := range inferred {
+			inferred[i].Type = loader.NonVendorPath(typ.Package.PkgPath) + "." + typ.Name
+		}
+		p.InferredListTypes[typ] = inferred
+	}
+}
+
+// lookupSchema fetches the schema for the given type from Schemata.
+func (p *Parser) lookupSchema(typ TypeIdent) (apiext.JSONSchemaProps, bool) {
+	schema, known := p.Schemata[typ]
+	return schema, known
 }
 
 func (p *Parser) NeedFlattenedSchemaFor(typ TypeIdent) {
@@ -104,6 +104,54 @@ var _ = Describe("CRD Generation From Parsing to CustomResourceDefinition", func
 		Expect(parser.CustomResourceDefinitions[groupKind]).To(Equal(crd), "type not as expected, check pkg/crd/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(parser.CustomResourceDefinitions[groupKind], crd))
 	})
 
+	It("should apply several union markers on a type in a fixed order", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkgs).To(HaveLen(1))
+		cronJobPkg := pkgs[0]
+
+		By("generating the schema of a type with several union markers a few times")
+		reg := &markers.Registry{}
+		Expect(crdmarkers.Register(reg)).To(Succeed())
+		var schemata []apiext.JSONSchemaProps
+		for i := 0; i < 10; i++ {
+			parser := &crd.Parser{
+				Collector: &markers.Collector{Registry: reg},
+				Checker:   &loader.TypeChecker{},
+			}
+			parser.NeedPackage(cronJobPkg)
+			typ := crd.TypeIdent{Package: cronJobPkg, Name: "MultiOneOfObject"}
+			parser.NeedSchemaFor(typ)
+			schemata = append(schemata, parser.Schemata[typ])
+		}
+
+		Expect(cronJobPkg.Errors).To(BeEmpty())
+
+		By("checking that the markers are applied in order of name")
+		required := func(fields ...string) apiext.JSONSchemaProps {
+			return apiext.JSONSchemaProps{Required: fields}
+		}
+		schema := schemata[0]
+		Expect(schema.Not).To(Equal(&apiext.JSONSchemaProps{AnyOf: []apiext.JSONSchemaProps{required("configMap", "inline")}}))
+		Expect(schema.OneOf).To(Equal([]apiext.JSONSchemaProps{required("secret"), required("inline")}))
+		Expect(schema.AllOf).To(Equal([]apiext.JSONSchemaProps{
+			{Not: &apiext.JSONSchemaProps{AnyOf: []apiext.JSONSchemaProps{required("secret", "inline")}}},
+			{OneOf: []apiext.JSONSchemaProps{required("configMap"), required("secret")}},
+		}))
+
+		By("checking that the schema is the same every time")
+		for _, other := range schemata[1:] {
+			Expect(other).To(Equal(schema), "Diff:\n\n%s", cmp.Diff(other, schema))
+		}
+	})
+
 	It("should skip api internal package", func() {
 		By("switching into testdata to appease go modules")
 		cwd, err := os.Getwd()
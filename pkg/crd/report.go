@@ -0,0 +1,422 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// reportTopN is the number of entries listed in each of the "largest ..."
+// sections of a report.
+const reportTopN = 10
+
+// KindReport summarizes the size and complexity of the CRD generated for
+// a single group-kind.
+type KindReport struct {
+	// Name is the name of the CRD.
+	Name string `json:"name"`
+	// Group is the API group of the kind.
+	Group string `json:"group"`
+	// Kind is the kind itself.
+	Kind string `json:"kind"`
+	// FeatureSet is the feature set the CRD was generated for, if any.
+	FeatureSet string `json:"featureSet,omitempty"`
+	// Size is the size of the largest serialized (JSON) form of the CRD,
+	// across all generated CRD versions.
+	Size int `json:"size"`
+	// UntrimmedSize is the size of the CRD before its descriptions were
+	// trimmed to fit the generator's MaxSize, if they were.
+	UntrimmedSize int `json:"untrimmedSize,omitempty"`
+	// RemovedFeatures describes the schema features removed because the
+	// target Kubernetes version doesn't support them.
+	RemovedFeatures []string `json:"removedFeatures,omitempty"`
+	// Versions contains statistics for the schema of each version of the kind.
+	Versions []VersionReport `json:"versions"`
+}
+
+// VersionReport summarizes the size and complexity of the schema of a
+// single version of a kind.
+type VersionReport struct {
+	// Name is the name of the version.
+	Name string `json:"name"`
+	// Size is the size of the serialized (JSON) schema for this version.
+	Size int `json:"size"`
+	// MaxDepth is the deepest level of nesting of properties, items, and
+	// additional properties in the schema.
+	MaxDepth int `json:"maxDepth"`
+	// Properties is the total number of properties, at any depth, in the schema.
+	Properties int `json:"properties"`
+	// LargestFields lists the fields with the largest serialized subtrees.
+	LargestFields []FieldSize `json:"largestFields,omitempty"`
+	// LargestExternalTypes lists the types from other packages that
+	// contribute the most to the size of the schema.
+	LargestExternalTypes []TypeSize `json:"largestExternalTypes,omitempty"`
+	// InferredListTypes lists the list types inferred for fields in the
+	// schema, if list type inference is enabled.
+	InferredListTypes []InferredListType `json:"inferredListTypes,omitempty"`
+}
+
+// FieldSize is the serialized size of the schema of a single field.
+type FieldSize struct {
+	// Path is the JSONPath-like path to the field (e.g. `.spec.items[*].name`).
+	Path string `json:"path"`
+	// Size is the serialized (JSON) size of the field's schema.
+	Size int `json:"size"`
+}
+
+// TypeSize is the size contributed to a schema by some Go type.
+type TypeSize struct {
+	// Type is the fully qualified name of the type.
+	Type string `json:"type"`
+	// Occurrences is the number of times the type occurs in the schema.
+	Occurrences int `json:"occurrences"`
+	// Size is the total serialized (JSON) size of all occurrences of the
+	// type, before any description trimming.
+	Size int `json:"size"`
+}
+
+// kindReport computes the report for the given CRD, which must have been
+// generated by the given parser.  size is the serialized size of the CRD.
+func kindReport(p *Parser, groupKind schema.GroupKind, crd *apiext.CustomResourceDefinition, size int) (KindReport, error) {
+	report := KindReport{
+		Name:  crd.Name,
+		Group: groupKind.Group,
+		Kind:  groupKind.Kind,
+		Size:  size,
+	}
+
+	for _, ver := range crd.Spec.Versions {
+		if ver.Schema == nil || ver.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		verReport := VersionReport{Name: ver.Name}
+
+		var fields []FieldSize
+		var err error
+		verReport.Size, err = serializedSize(ver.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return KindReport{}, err
+		}
+		if err := collectFieldStats(ver.Schema.OpenAPIV3Schema, "", 0, &verReport, &fields); err != nil {
+			return KindReport{}, err
+		}
+		sort.SliceStable(fields, func(i, j int) bool {
+			if fields[i].Size != fields[j].Size {
+				return fields[i].Size > fields[j].Size
+			}
+			return fields[i].Path < fields[j].Path
+		})
+		if len(fields) > reportTopN {
+			fields = fields[:reportTopN]
+		}
+		verReport.LargestFields = fields
+
+		for pkg, gv := range p.GroupVersions {
+			if gv.Group != groupKind.Group || gv.Version != ver.Name {
+				continue
+			}
+			typeIdent := TypeIdent{Package: pkg, Name: groupKind.Kind}
+			if _, known := p.Types[typeIdent]; !known {
+				continue
+			}
+			extTypes, err := externalTypeSizes(p, typeIdent)
+			if err != nil {
+				return KindReport{}, err
+			}
+			verReport.LargestExternalTypes = extTypes
+			verReport.InferredListTypes = inferredListTypesFrom(p, typeIdent)
+			break
+		}
+
+		report.Versions = append(report.Versions, verReport)
+	}
+
+	return report, nil
+}
+
+// collectFieldStats records the depth, property count, and the sizes of the
+// fields of the given schema (found at the given path and depth).
+func collectFieldStats(props *apiext.JSONSchemaProps, path string, depth int, verReport *VersionReport, fields *[]FieldSize) error {
+	if depth > verReport.MaxDepth {
+		verReport.MaxDepth = depth
+	}
+	if path != "" {
+		size, err := serializedSize(props)
+		if err != nil {
+			return err
+		}
+		*fields = append(*fields, FieldSize{Path: path, Size: size})
+	}
+
+	for name := range props.Properties {
+		verReport.Properties++
+		prop := props.Properties[name]
+		if err := collectFieldStats(&prop, path+"."+name, depth+1, verReport, fields); err != nil {
+			return err
+		}
+	}
+	if props.Items != nil && props.Items.Schema != nil {
+		if err := collectFieldStats(props.Items.Schema, path+"[*]", depth+1, verReport, fields); err != nil {
+			return err
+		}
+	}
+	if props.AdditionalProperties != nil && props.AdditionalProperties.Schema != nil {
+		if err := collectFieldStats(props.AdditionalProperties.Schema, path+".*", depth+1, verReport, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// externalTypeSizes computes the size contributed by each type outside of
+// the package of the given root type, following references through types
+// in the root package.  References inside external types are attributed to
+// the external type itself.
+func externalTypeSizes(p *Parser, root TypeIdent) ([]TypeSize, error) {
+	sizes := make(map[TypeIdent]*TypeSize)
+	inProgress := make(map[TypeIdent]bool)
+
+	var visit func(typ TypeIdent) error
+	visit = func(typ TypeIdent) error {
+		if inProgress[typ] {
+			// recursive types are only counted once per path
+			return nil
+		}
+		inProgress[typ] = true
+		defer delete(inProgress, typ)
+
+		p.NeedSchemaFor(typ)
+		typSchema := p.Schemata[typ]
+		var refErr error
+		EditSchema(typSchema.DeepCopy(), refVisitor(func(ref string) {
+			if refErr != nil {
+				return
+			}
+			refIdent, err := identFromRef(ref, typ.Package)
+			if err != nil {
+				refErr = err
+				return
+			}
+			if refIdent.Package == nil {
+				// unknown packages get reported as errors during flattening
+				return
+			}
+			if refIdent.Package != root.Package {
+				refErr = addExternalSize(p, refIdent, sizes)
+				return
+			}
+			refErr = visit(refIdent)
+		}))
+		return refErr
+	}
+	if err := visit(root); err != nil {
+		return nil, err
+	}
+
+	res := make([]TypeSize, 0, len(sizes))
+	for _, size := range sizes {
+		res = append(res, *size)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Size != res[j].Size {
+			return res[i].Size > res[j].Size
+		}
+		return res[i].Type < res[j].Type
+	})
+	if len(res) > reportTopN {
+		res = res[:reportTopN]
+	}
+	return res, nil
+}
+
+// inferredListTypesFrom collects the list types inferred for all types
+// reachable from the given root type.
+func inferredListTypesFrom(p *Parser, root TypeIdent) []InferredListType {
+	var res []InferredListType
+	seen := make(map[TypeIdent]bool)
+
+	var visit func(typ TypeIdent)
+	visit = func(typ TypeIdent) {
+		if seen[typ] {
+			return
+		}
+		seen[typ] = true
+		res = append(res, p.InferredListTypes[typ]...)
+
+		typSchema := p.Schemata[typ]
+		EditSchema(typSchema.DeepCopy(), refVisitor(func(ref string) {
+			refIdent, err := identFromRef(ref, typ.Package)
+			if err != nil || refIdent.Package == nil {
+				return
+			}
+			visit(refIdent)
+		}))
+	}
+	visit(root)
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Type != res[j].Type {
+			return res[i].Type < res[j].Type
+		}
+		return res[i].Field < res[j].Field
+	})
+	return res
+}
+
+// addExternalSize records an occurrence of the given external type.
+func addExternalSize(p *Parser, typ TypeIdent, sizes map[TypeIdent]*TypeSize) error {
+	entry, seen := sizes[typ]
+	if seen {
+		entry.Size += entry.Size / entry.Occurrences
+		entry.Occurrences++
+		return nil
+	}
+
+	p.NeedFlattenedSchemaFor(typ)
+	flattened := p.FlattenedSchemata[typ]
+	size, err := serializedSize(&flattened)
+	if err != nil {
+		return err
+	}
+	sizes[typ] = &TypeSize{
+		Type:        loader.NonVendorPath(typ.Package.PkgPath) + "." + typ.Name,
+		Occurrences: 1,
+		Size:        size,
+	}
+	return nil
+}
+
+// refVisitor calls itself for each reference in a schema.
+type refVisitor func(ref string)
+
+func (v refVisitor) Visit(schema *apiext.JSONSchemaProps) SchemaVisitor {
+	if schema == nil {
+		return v
+	}
+	if schema.Ref != nil && len(*schema.Ref) > 0 {
+		v(*schema.Ref)
+		// don't follow the reference, we handle it ourselves
+		return nil
+	}
+	return v
+}
+
+// serializedSize returns the length of the JSON serialization of the given object.
+func serializedSize(obj interface{}) (int, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return 0, err
+	}
+	return len(raw), nil
+}
+
+// checkReportFormat checks that the extension of the given path indicates a
+// format that writeReport knows how to write.
+func checkReportFormat(path string) error {
+	switch filepath.Ext(path) {
+	case ".json", ".md":
+		return nil
+	default:
+		return fmt.Errorf("unknown report format for %q, must end in .json or .md", path)
+	}
+}
+
+// writeReport writes the given reports in the format indicated by the
+// extension of the given path (JSON for `.json`, Markdown for `.md`).
+func writeReport(out io.Writer, path string, reports []KindReport) error {
+	switch filepath.Ext(path) {
+	case ".json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	case ".md":
+		return writeMarkdownReport(out, reports)
+	default:
+		return fmt.Errorf("unknown report format for %q, must end in .json or .md", path)
+	}
+}
+
+// writeMarkdownReport writes the given reports as Markdown.
+func writeMarkdownReport(out io.Writer, reports []KindReport) error {
+	var b strings.Builder
+	b.WriteString("# CRD Report\n")
+	for _, report := range reports {
+		if report.FeatureSet != "" {
+			fmt.Fprintf(&b, "\n## %s (%s)\n\n", report.Name, report.FeatureSet)
+		} else {
+			fmt.Fprintf(&b, "\n## %s\n\n", report.Name)
+		}
+		if report.UntrimmedSize != 0 {
+			fmt.Fprintf(&b, "Serialized size: %d bytes (%d before trimming descriptions)\n", report.Size, report.UntrimmedSize)
+		} else {
+			fmt.Fprintf(&b, "Serialized size: %d bytes\n", report.Size)
+		}
+		if len(report.RemovedFeatures) > 0 {
+			b.WriteString("\nRemoved for the target Kubernetes version:\n\n")
+			for _, removal := range report.RemovedFeatures {
+				fmt.Fprintf(&b, "- %s\n", removal)
+			}
+		}
+
+		for _, ver := range report.Versions {
+			fmt.Fprintf(&b, "\n### %s\n\n", ver.Name)
+			b.WriteString("| Schema size (bytes) | Max depth | Properties |\n")
+			b.WriteString("|---|---|---|\n")
+			fmt.Fprintf(&b, "| %d | %d | %d |\n", ver.Size, ver.MaxDepth, ver.Properties)
+
+			if len(ver.LargestFields) > 0 {
+				b.WriteString("\nLargest fields:\n\n")
+				b.WriteString("| Field | Size (bytes) |\n")
+				b.WriteString("|---|---|\n")
+				for _, field := range ver.LargestFields {
+					fmt.Fprintf(&b, "| `%s` | %d |\n", field.Path, field.Size)
+				}
+			}
+
+			if len(ver.LargestExternalTypes) > 0 {
+				b.WriteString("\nLargest external types:\n\n")
+				b.WriteString("| Type | Occurrences | Size (bytes) |\n")
+				b.WriteString("|---|---|---|\n")
+				for _, typ := range ver.LargestExternalTypes {
+					fmt.Fprintf(&b, "| `%s` | %d | %d |\n", typ.Type, typ.Occurrences, typ.Size)
+				}
+			}
+
+			if len(ver.InferredListTypes) > 0 {
+				b.WriteString("\nInferred list types:\n\n")
+				b.WriteString("| Type | Field | List type | Keys | Inferred from |\n")
+				b.WriteString("|---|---|---|---|---|\n")
+				for _, inferred := range ver.InferredListTypes {
+					fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s | %s |\n", inferred.Type, inferred.Field, inferred.ListType, strings.Join(inferred.MapKeys, ", "), inferred.Source)
+				}
+			}
+		}
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+var _ = Describe("CRD Report", func() {
+	It("should report the size and complexity of each generated CRD", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the generation runtime")
+		var gen genall.Generator = crd.Generator{Report: "report.json"}
+		rt, err := genall.Generators{&gen}.ForRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		outputDir, err := ioutil.TempDir("", "controller-tools-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outputDir)
+		rt.OutputRules.Default = genall.OutputToDirectory(outputDir)
+
+		By("running the generator")
+		Expect(rt.Run()).To(BeFalse(), "unexpectedly had errors")
+
+		By("reading the report")
+		rawReport, err := ioutil.ReadFile(filepath.Join(outputDir, "report.json"))
+		Expect(err).NotTo(HaveOccurred())
+		var reports []crd.KindReport
+		Expect(json.Unmarshal(rawReport, &reports)).To(Succeed())
+
+		By("checking the report for the CronJob kind")
+		Expect(reports).To(HaveLen(1))
+		report := reports[0]
+		Expect(report.Name).To(Equal("cronjobs.testdata.kubebuilder.io"))
+		Expect(report.Size).To(BeNumerically(">", 0))
+		Expect(report.Versions).To(HaveLen(1))
+
+		ver := report.Versions[0]
+		Expect(ver.Name).To(Equal("v1"))
+		Expect(ver.Size).To(BeNumerically("<", report.Size))
+		Expect(ver.Properties).To(BeNumerically(">", 0))
+		Expect(ver.MaxDepth).To(BeNumerically(">", 1))
+		Expect(ver.LargestFields).To(HaveLen(10))
+		Expect(ver.LargestFields[0].Path).To(Equal(".spec"))
+		Expect(ver.LargestExternalTypes[0].Type).To(Equal("k8s.io/api/batch/v1beta1.JobTemplateSpec"))
+	})
+
+	It("should report the size before trimming descriptions to fit the maximum size", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the generation runtime")
+		maxSize := 100000
+		var gen genall.Generator = crd.Generator{Report: "report.json", MaxSize: &maxSize}
+		rt, err := genall.Generators{&gen}.ForRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		outputDir, err := ioutil.TempDir("", "controller-tools-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outputDir)
+		rt.OutputRules.Default = genall.OutputToDirectory(outputDir)
+
+		By("running the generator")
+		Expect(rt.Run()).To(BeFalse(), "unexpectedly had errors")
+
+		By("reading the report")
+		rawReport, err := ioutil.ReadFile(filepath.Join(outputDir, "report.json"))
+		Expect(err).NotTo(HaveOccurred())
+		var reports []crd.KindReport
+		Expect(json.Unmarshal(rawReport, &reports)).To(Succeed())
+
+		By("checking the sizes before and after trimming")
+		Expect(reports).To(HaveLen(1))
+		Expect(reports[0].Size).To(BeNumerically("<=", maxSize))
+		Expect(reports[0].UntrimmedSize).To(BeNumerically(">", maxSize))
+	})
+
+	It("should refuse to remove features unsupported by the target Kubernetes version without a report", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		for _, report := range []string{"", "report.json"} {
+			By("loading the generation runtime")
+			var gen genall.Generator = crd.Generator{
+				CRDVersions:             []string{"v1beta1"},
+				TargetKubernetesVersion: "1.15",
+				Report:                  report,
+			}
+			rt, err := genall.Generators{&gen}.ForRoots(".")
+			Expect(err).NotTo(HaveOccurred())
+
+			outputDir, err := ioutil.TempDir("", "controller-tools-test")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(outputDir)
+			rt.OutputRules.Default = genall.OutputToDirectory(outputDir)
+
+			By("running the generator")
+			hadErrs := rt.Run()
+			files, err := ioutil.ReadDir(outputDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			if report == "" {
+				By("checking that nothing was written")
+				Expect(hadErrs).To(BeTrue())
+				Expect(files).To(BeEmpty())
+				continue
+			}
+
+			By("checking that the removals are in the report")
+			Expect(hadErrs).To(BeFalse(), "unexpectedly had errors")
+			rawReport, err := ioutil.ReadFile(filepath.Join(outputDir, "report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			var reports []crd.KindReport
+			Expect(json.Unmarshal(rawReport, &reports)).To(Succeed())
+			Expect(reports).To(HaveLen(1))
+			Expect(reports[0].RemovedFeatures).To(ContainElement("x-kubernetes-list-type at v1.spec.associativeList (requires Kubernetes 1.16+)"))
+		}
+	})
+
+	It("should refuse unknown report formats before writing anything", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the generation runtime")
+		var gen genall.Generator = crd.Generator{Report: "report.txt"}
+		rt, err := genall.Generators{&gen}.ForRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		outputDir, err := ioutil.TempDir("", "controller-tools-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outputDir)
+		rt.OutputRules.Default = genall.OutputToDirectory(outputDir)
+
+		By("running the generator")
+		Expect(rt.Run()).To(BeTrue(), "unexpectedly had no errors")
+
+		By("checking that nothing was written")
+		files, err := ioutil.ReadDir(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+})
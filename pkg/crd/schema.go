@@ -21,10 +21,12 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"sort"
 	"strings"
 
 	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
 	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
@@ -56,9 +58,33 @@ type applyFirstMarker interface {
 	ApplyFirst()
 }
 
+// applyLastMarker is applied after all other markers, e.g. to extend what
+// they set.  It's the same sort of hack as applyFirstMarker.
+type applyLastMarker interface {
+	ApplyLast()
+}
+
+// markerPhase returns when the given marker should be applied relative to
+// the others: 0 for "apply first", 2 for "apply last", or 1 otherwise.
+func markerPhase(marker interface{}) int {
+	if _, isApplyFirst := marker.(applyFirstMarker); isApplyFirst {
+		return 0
+	}
+	if _, isApplyLast := marker.(applyLastMarker); isApplyLast {
+		return 2
+	}
+	return 1
+}
+
 // schemaRequester knows how to marker that another schema (e.g. via an external reference) is necessary.
 type schemaRequester interface {
 	NeedSchemaFor(typ TypeIdent)
+	// lookupSchema fetches a previously requested schema, returning false
+	// if it's not known (yet).
+	lookupSchema(typ TypeIdent) (apiext.JSONSchemaProps, bool)
+	// LookupType fetches the information for the given type, or nil
+	// if it's not known.
+	LookupType(pkg *loader.Package, name string) *markers.TypeInfo
 }
 
 // schemaContext stores and provides information across a hierarchy of schema generation.
@@ -70,6 +96,14 @@ type schemaContext struct {
 	PackageMarkers  markers.MarkerValues
 
 	allowDangerousTypes bool
+
+	// inferListTypes indicates that list types should be inferred for
+	// fields that don't specify one, recording them in inferredListTypes.
+	inferListTypes    bool
+	inferredListTypes *[]InferredListType
+
+	// featureGates contains the enabled feature gates, if gating is enabled.
+	featureGates map[string]bool
 }
 
 // newSchemaContext constructs a new schemaContext for the given package and schema requester.
@@ -91,9 +125,27 @@ func (c *schemaContext) ForInfo(info *markers.TypeInfo) *schemaContext {
 		info:                info,
 		schemaRequester:     c.schemaRequester,
 		allowDangerousTypes: c.allowDangerousTypes,
+		inferListTypes:      c.inferListTypes,
+		inferredListTypes:   c.inferredListTypes,
+		featureGates:        c.featureGates,
 	}
 }
 
+// gateEnabled checks if the given feature gate is enabled.
+func (c *schemaContext) gateEnabled(gate string) bool {
+	return c.featureGates == nil || c.featureGates[gate]
+}
+
+// fieldEnabled checks if all of the feature gates of the given field are enabled.
+func (c *schemaContext) fieldEnabled(field markers.FieldInfo) bool {
+	for _, gate := range field.Markers["kubebuilder:featureGate"] {
+		if !c.gateEnabled(string(gate.(crdmarkers.FeatureGate))) {
+			return false
+		}
+	}
+	return true
+}
+
 // requestSchema asks for the schema for a type in the package with the
 // given import path.
 func (c *schemaContext) requestSchema(pkgPath, typeName string) {
@@ -111,49 +163,78 @@ func (c *schemaContext) requestSchema(pkgPath, typeName string) {
 func infoToSchema(ctx *schemaContext) *apiext.JSONSchemaProps {
 	if obj := ctx.pkg.Types.Scope().Lookup(ctx.info.Name); obj != nil && implementsJSONMarshaler(obj.Type()) {
 		schema := &apiext.JSONSchemaProps{Type: "Any"}
-		applyMarkers(ctx, ctx.info.Markers, schema, ctx.info.RawSpec.Type)
+		applyMarkers(ctx, ctx.info.Markers, ctx.info.MarkerPositions, schema, ctx.info.RawSpec.Type)
 		return schema
 	}
 	return typeToSchema(ctx, ctx.info.RawSpec.Type)
 }
 
-// applyMarkers applies schema markers to the given schema, respecting "apply first" markers.
-func applyMarkers(ctx *schemaContext, markerSet markers.MarkerValues, props *apiext.JSONSchemaProps, node ast.Node) {
-	// apply "apply first" markers first...
-	for _, markerValues := range markerSet {
-		for _, markerValue := range markerValues {
-			if _, isApplyFirst := markerValue.(applyFirstMarker); !isApplyFirst {
-				continue
-			}
-
-			schemaMarker, isSchemaMarker := markerValue.(SchemaMarker)
-			if !isSchemaMarker {
-				continue
-			}
-
-			if err := schemaMarker.ApplyToSchema(props); err != nil {
-				ctx.pkg.AddError(loader.ErrFromNode(err /* an okay guess */, node))
+// applyMarkers applies schema markers to the given schema, respecting "apply first"
+// and "apply last" markers, and otherwise in order of marker name.
+// Errors are attached to the position of the offending marker, if known, or the given node otherwise.
+//
+// Markers gated by a disabled feature gate aren't applied, but are still
+// checked against a copy of the schema, so that mistakes in them are reported
+// regardless of the feature gates.
+func applyMarkers(ctx *schemaContext, markerSet markers.MarkerValues, positions markers.MarkerPositions, props *apiext.JSONSchemaProps, node ast.Node) {
+	// apply markers in a fixed order within each phase, since some of them
+	// (like the union markers) build on what the others set
+	names := make([]string, 0, len(markerSet))
+	for name := range markerSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for phase := 0; phase <= 2; phase++ {
+		for _, name := range names {
+			for i, markerValue := range markerSet[name] {
+				if markerPhase(markerValue) != phase {
+					continue
+				}
+
+				schemaMarker, isSchemaMarker := markerValue.(SchemaMarker)
+				if !isSchemaMarker {
+					continue
+				}
+				target := props
+				if gated, isGated := markerValue.(crdmarkers.FeatureGatedMarker); isGated && !ctx.gateEnabled(gated.GatedBy()) {
+					target = props.DeepCopy()
+				}
+
+				if err := applyMarker(name, schemaMarker, target); err != nil {
+					ctx.pkg.AddError(markerErr(err, positions, name, i, node))
+				}
 			}
 		}
 	}
+}
 
-	// ...then the rest of the markers
-	for _, markerValues := range markerSet {
-		for _, markerValue := range markerValues {
-			if _, isApplyFirst := markerValue.(applyFirstMarker); isApplyFirst {
-				// skip apply-first markers, which were already applied
-				continue
-			}
-
-			schemaMarker, isSchemaMarker := markerValue.(SchemaMarker)
-			if !isSchemaMarker {
-				continue
-			}
-			if err := schemaMarker.ApplyToSchema(props); err != nil {
-				ctx.pkg.AddError(loader.ErrFromNode(err /* an okay guess */, node))
-			}
+// applyMarker applies the given schema marker to the given schema, or to the
+// schema of its items or values, for markers with the corresponding prefixes.
+func applyMarker(name string, marker SchemaMarker, props *apiext.JSONSchemaProps) error {
+	target := props
+	switch {
+	case strings.HasPrefix(name, crdmarkers.ItemsMarkerPrefix):
+		if props.Type != "array" || props.Items == nil || props.Items.Schema == nil {
+			return fmt.Errorf("must apply %s to a list", name)
 		}
+		target = props.Items.Schema
+	case strings.HasPrefix(name, crdmarkers.ValuesMarkerPrefix):
+		if props.Type != "object" || props.AdditionalProperties == nil || props.AdditionalProperties.Schema == nil {
+			return fmt.Errorf("must apply %s to a map", name)
+		}
+		target = props.AdditionalProperties.Schema
+	}
+	return marker.ApplyToSchema(target)
+}
+
+// markerErr attaches the given error to the position of the i-th value of the
+// given marker, falling back to the given node if that position isn't known.
+func markerErr(err error, positions markers.MarkerPositions, name string, i int, node ast.Node) error {
+	if pos := positions.Get(name, i); pos.IsValid() {
+		return loader.ErrFromPos(err, pos)
 	}
+	return loader.ErrFromNode(err /* an okay guess */, node)
 }
 
 // typeToSchema creates a schema for the given AST type.
@@ -180,7 +261,7 @@ func typeToSchema(ctx *schemaContext, rawType ast.Expr) *apiext.JSONSchemaProps
 
 	props.Description = ctx.info.Doc
 
-	applyMarkers(ctx, ctx.info.Markers, props, rawType)
+	applyMarkers(ctx, ctx.info.Markers, ctx.info.MarkerPositions, props, rawType)
 
 	return props
 }
@@ -336,6 +417,7 @@ func structToSchema(ctx *schemaContext, structType *ast.StructType) *apiext.JSON
 		return props
 	}
 
+	var union unionInfo
 	for _, field := range ctx.info.Fields {
 		jsonTag, hasTag := field.Tag.Lookup("json")
 		if !hasTag {
@@ -348,6 +430,10 @@ func structToSchema(ctx *schemaContext, structType *ast.StructType) *apiext.JSON
 			// skipped fields have the tag "-" (note that "-," means the field is named "-")
 			continue
 		}
+		if !ctx.fieldEnabled(field) {
+			// fields behind disabled feature gates don't exist as far as the schema is concerned
+			continue
+		}
 
 		inline := false
 		omitEmpty := false
@@ -387,7 +473,11 @@ func structToSchema(ctx *schemaContext, structType *ast.StructType) *apiext.JSON
 		propSchema := typeToSchema(ctx.ForInfo(&markers.TypeInfo{}), field.RawField.Type)
 		propSchema.Description = field.Doc
 
-		applyMarkers(ctx, field.Markers, propSchema, field.RawField)
+		applyMarkers(ctx, field.Markers, field.MarkerPositions, propSchema, field.RawField)
+		if ctx.inferListTypes {
+			inferListType(ctx, field, fieldName, propSchema)
+		}
+		checkTopology(ctx, field, propSchema)
 
 		if inline {
 			props.AllOf = append(props.AllOf, *propSchema)
@@ -395,8 +485,11 @@ func structToSchema(ctx *schemaContext, structType *ast.StructType) *apiext.JSON
 		}
 
 		props.Properties[fieldName] = *propSchema
+		union.addField(field, fieldName)
 	}
 
+	union.applyTo(ctx, props)
+
 	return props
 }
 
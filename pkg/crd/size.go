@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// SizeFunc computes the serialized size of a CRD, in bytes.
+type SizeFunc func(crd *apiext.CustomResourceDefinition) (int, error)
+
+// TrimDescriptionsToFit progressively truncates the descriptions in the
+// schemata of the given CRD until sizeOf reports a size of at most maxSize,
+// returning the final size.
+//
+// Trimming starts with the deepest descriptions in each schema, shortening
+// the longest ones first, and only moves up towards the root of the schema
+// once the descriptions below have been dropped entirely.  This keeps
+// useful top-level documentation around for as long as possible.
+//
+// If the CRD doesn't fit even without any descriptions, an error is
+// returned, and the CRD is left with all descriptions removed.
+func TrimDescriptionsToFit(crd *apiext.CustomResourceDefinition, maxSize int, sizeOf SizeFunc) (int, error) {
+	size, err := sizeOf(crd)
+	if err != nil {
+		return 0, err
+	}
+	if size <= maxSize {
+		return size, nil
+	}
+
+	var stats descStatsVisitor
+	for _, ver := range crd.Spec.Versions {
+		if ver.Schema == nil || ver.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		EditSchema(ver.Schema.OpenAPIV3Schema, &stats)
+	}
+
+	for depth := stats.maxDepth; depth >= 0; depth-- {
+		for maxLen := stats.maxLen * 3 / 4; ; maxLen = maxLen * 3 / 4 {
+			for _, ver := range crd.Spec.Versions {
+				if ver.Schema == nil || ver.Schema.OpenAPIV3Schema == nil {
+					continue
+				}
+				truncateDescriptionBelow(ver.Schema.OpenAPIV3Schema, maxLen, depth)
+			}
+
+			size, err = sizeOf(crd)
+			if err != nil {
+				return 0, err
+			}
+			if size <= maxSize {
+				return size, nil
+			}
+			if maxLen == 0 {
+				break
+			}
+		}
+	}
+
+	return size, fmt.Errorf("CRD %s is %d bytes even without descriptions, which exceeds the maximum size of %d bytes", crd.Name, size, maxSize)
+}
+
+// descStatsVisitor records the deepest level at which a description occurs
+// in a schema, and the length of the longest description.
+type descStatsVisitor struct {
+	maxDepth int
+	maxLen   int
+
+	// depth is the depth of the schema currently being visited.
+	depth int
+}
+
+func (v *descStatsVisitor) Visit(schema *apiext.JSONSchemaProps) SchemaVisitor {
+	if schema == nil {
+		v.depth--
+		return v
+	}
+	if schema.Description != "" {
+		if v.depth > v.maxDepth {
+			v.maxDepth = v.depth
+		}
+		if len(schema.Description) > v.maxLen {
+			v.maxLen = len(schema.Description)
+		}
+	}
+	v.depth++
+	return v
+}
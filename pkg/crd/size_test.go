@@ -0,0 +1,112 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+)
+
+var _ = Describe("TrimDescriptionsToFit", func() {
+	jsonSize := func(obj *apiext.CustomResourceDefinition) (int, error) {
+		raw, err := json.Marshal(obj)
+		return len(raw), err
+	}
+
+	var sampleCRD func() *apiext.CustomResourceDefinition
+	BeforeEach(func() {
+		sampleCRD = func() *apiext.CustomResourceDefinition {
+			return &apiext.CustomResourceDefinition{
+				Spec: apiext.CustomResourceDefinitionSpec{
+					Versions: []apiext.CustomResourceDefinitionVersion{{
+						Name: "v1",
+						Schema: &apiext.CustomResourceValidation{
+							OpenAPIV3Schema: &apiext.JSONSchemaProps{
+								Description: "The top-level description. It is useful.",
+								Properties: map[string]apiext.JSONSchemaProps{
+									"spec": {
+										Description: "The spec description. It describes the spec.",
+										Properties: map[string]apiext.JSONSchemaProps{
+											"embedded": {
+												Description: "A long description of some embedded type. It goes on. And on. And on.",
+											},
+										},
+									},
+								},
+							},
+						},
+					}},
+				},
+			}
+		}
+	})
+
+	It("should leave CRDs that already fit untouched", func() {
+		obj := sampleCRD()
+		size, err := jsonSize(obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		newSize, err := crd.TrimDescriptionsToFit(obj, size, jsonSize)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newSize).To(Equal(size))
+		Expect(obj).To(Equal(sampleCRD()))
+	})
+
+	It("should trim the deepest descriptions first", func() {
+		obj := sampleCRD()
+		size, err := jsonSize(obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		newSize, err := crd.TrimDescriptionsToFit(obj, size-10, jsonSize)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newSize).To(BeNumerically("<=", size-10))
+
+		expected := sampleCRD()
+		schema := obj.Spec.Versions[0].Schema.OpenAPIV3Schema
+		expectedSchema := expected.Spec.Versions[0].Schema.OpenAPIV3Schema
+		Expect(schema.Description).To(Equal(expectedSchema.Description))
+		Expect(schema.Properties["spec"].Description).To(Equal(expectedSchema.Properties["spec"].Description))
+		Expect(schema.Properties["spec"].Properties["embedded"].Description).To(Equal("A long description of some embedded type."))
+	})
+
+	It("should move up the schema once deeper descriptions are gone", func() {
+		obj := sampleCRD()
+		size, err := jsonSize(obj)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = crd.TrimDescriptionsToFit(obj, size-100, jsonSize)
+		Expect(err).NotTo(HaveOccurred())
+
+		schema := obj.Spec.Versions[0].Schema.OpenAPIV3Schema
+		Expect(schema.Description).To(Equal("The top-level description. It is useful."))
+		Expect(schema.Properties["spec"].Properties["embedded"].Description).To(BeEmpty())
+		Expect(len(schema.Properties["spec"].Description)).To(BeNumerically("<", len("The spec description. It describes the spec.")))
+	})
+
+	It("should fail if the CRD doesn't fit without any descriptions", func() {
+		obj := sampleCRD()
+		_, err := crd.TrimDescriptionsToFit(obj, 10, jsonSize)
+		Expect(err).To(HaveOccurred())
+		Expect(obj.Spec.Versions[0].Schema.OpenAPIV3Schema.Description).To(BeEmpty())
+	})
+})
@@ -0,0 +1,194 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// KubeVersion is a Kubernetes (minor) release, like 1.18.
+type KubeVersion struct {
+	Major int
+	Minor int
+}
+
+// ParseKubeVersion parses a Kubernetes version like `1.18`, `v1.18`, or
+// `1.18.3` (patch versions are ignored).
+func ParseKubeVersion(raw string) (KubeVersion, error) {
+	parts := strings.Split(strings.TrimPrefix(raw, "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return KubeVersion{}, fmt.Errorf("invalid Kubernetes version %q, must be of the form 1.18", raw)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return KubeVersion{}, fmt.Errorf("invalid Kubernetes version %q: %w", raw, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return KubeVersion{}, fmt.Errorf("invalid Kubernetes version %q: %w", raw, err)
+	}
+	return KubeVersion{Major: major, Minor: minor}, nil
+}
+
+// AtLeast checks if this version is the same as, or newer than, the given one.
+func (v KubeVersion) AtLeast(other KubeVersion) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	return v.Minor >= other.Minor
+}
+
+func (v KubeVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// schemaFeature is a schema feature that's only supported by newer API servers.
+type schemaFeature struct {
+	name  string
+	since KubeVersion
+	// strip removes the feature from the given schema, returning true
+	// if it was present.
+	strip func(props *apiext.JSONSchemaProps) bool
+}
+
+// schemaFeatures lists the schema features that aren't supported by all the
+// API server versions that support CRDs.
+//
+// Newer features, like CEL validation rules (x-kubernetes-validations, with
+// their messageExpression and reason fields) and selectable fields, aren't
+// listed, since the apiextensions types we build against (v0.18) can't
+// represent them, so they're never generated in the first place.
+var schemaFeatures = []schemaFeature{
+	{
+		name:  "default",
+		since: KubeVersion{1, 16},
+		strip: func(props *apiext.JSONSchemaProps) bool {
+			present := props.Default != nil
+			props.Default = nil
+			return present
+		},
+	},
+	{
+		name:  "x-kubernetes-list-type",
+		since: KubeVersion{1, 16},
+		strip: func(props *apiext.JSONSchemaProps) bool {
+			present := props.XListType != nil || len(props.XListMapKeys) > 0
+			props.XListType = nil
+			props.XListMapKeys = nil
+			return present
+		},
+	},
+	{
+		name:  "x-kubernetes-map-type",
+		since: KubeVersion{1, 17},
+		strip: func(props *apiext.JSONSchemaProps) bool {
+			present := props.XMapType != nil
+			props.XMapType = nil
+			return present
+		},
+	},
+}
+
+// checkTargetCRDVersions checks that the given CRD versions (e.g. v1) are
+// served by the given target Kubernetes version.
+func checkTargetCRDVersions(target KubeVersion, crdVersions []string) error {
+	for _, ver := range crdVersions {
+		switch {
+		case ver == "v1" && !target.AtLeast(KubeVersion{1, 16}):
+			return fmt.Errorf("v1 CRDs require Kubernetes 1.16+, but the target is %s; use crdVersions=v1beta1", target)
+		case ver == "v1beta1" && target.AtLeast(KubeVersion{1, 22}):
+			return fmt.Errorf("v1beta1 CRDs aren't served by Kubernetes 1.22+, but the target is %s; use crdVersions=v1", target)
+		}
+	}
+	return nil
+}
+
+// StripUnsupportedFeatures removes the schema features that the given target
+// Kubernetes version doesn't support from the given CRD, returning a
+// description of each removal.
+func StripUnsupportedFeatures(crd *apiext.CustomResourceDefinition, target KubeVersion) []string {
+	var removed []string
+	for _, ver := range crd.Spec.Versions {
+		if ver.Schema == nil || ver.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		stripFeatures(ver.Schema.OpenAPIV3Schema, target, ver.Name, &removed)
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// stripFeatures strips unsupported features from the given schema (found at
+// the given path) and all of its subschemata, wherever they're nested.
+func stripFeatures(props *apiext.JSONSchemaProps, target KubeVersion, path string, removed *[]string) {
+	for _, feature := range schemaFeatures {
+		if target.AtLeast(feature.since) {
+			continue
+		}
+		if feature.strip(props) {
+			*removed = append(*removed, fmt.Sprintf("%s at %s (requires Kubernetes %s+)", feature.name, path, feature.since))
+		}
+	}
+
+	for name := range props.Properties {
+		prop := props.Properties[name]
+		stripFeatures(&prop, target, path+"."+name, removed)
+		props.Properties[name] = prop
+	}
+	if props.Items != nil {
+		if props.Items.Schema != nil {
+			stripFeatures(props.Items.Schema, target, path+"[*]", removed)
+		}
+		for i := range props.Items.JSONSchemas {
+			stripFeatures(&props.Items.JSONSchemas[i], target, fmt.Sprintf("%s[%d]", path, i), removed)
+		}
+	}
+	if props.AdditionalItems != nil && props.AdditionalItems.Schema != nil {
+		stripFeatures(props.AdditionalItems.Schema, target, path+"[*]", removed)
+	}
+	if props.AdditionalProperties != nil && props.AdditionalProperties.Schema != nil {
+		stripFeatures(props.AdditionalProperties.Schema, target, path+".*", removed)
+	}
+	for pattern := range props.PatternProperties {
+		prop := props.PatternProperties[pattern]
+		stripFeatures(&prop, target, fmt.Sprintf("%s.patternProperties[%s]", path, pattern), removed)
+		props.PatternProperties[pattern] = prop
+	}
+	for name, dep := range props.Dependencies {
+		if dep.Schema != nil {
+			stripFeatures(dep.Schema, target, fmt.Sprintf("%s.dependencies[%s]", path, name), removed)
+		}
+	}
+	for name := range props.Definitions {
+		def := props.Definitions[name]
+		stripFeatures(&def, target, fmt.Sprintf("%s.definitions[%s]", path, name), removed)
+		props.Definitions[name] = def
+	}
+	for _, subSchemas := range [][]apiext.JSONSchemaProps{props.AllOf, props.OneOf, props.AnyOf} {
+		for i := range subSchemas {
+			stripFeatures(&subSchemas[i], target, path, removed)
+		}
+	}
+	if props.Not != nil {
+		stripFeatures(props.Not, target, path, removed)
+	}
+}
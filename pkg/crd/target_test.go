@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+)
+
+var _ = Describe("Target Kubernetes versions", func() {
+	It("should parse versions with or without a leading v or patch version", func() {
+		for _, raw := range []string{"1.17", "v1.17", "1.17.4"} {
+			ver, err := crd.ParseKubeVersion(raw)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ver).To(Equal(crd.KubeVersion{Major: 1, Minor: 17}))
+		}
+	})
+
+	It("should reject malformed versions", func() {
+		for _, raw := range []string{"1", "one.two", "1.x", "1.2.3.4"} {
+			_, err := crd.ParseKubeVersion(raw)
+			Expect(err).To(HaveOccurred(), raw)
+		}
+	})
+
+	Context("when stripping unsupported features", func() {
+		var sampleCRD func() *apiext.CustomResourceDefinition
+		BeforeEach(func() {
+			listType, mapType := "map", "atomic"
+			sampleCRD = func() *apiext.CustomResourceDefinition {
+				return &apiext.CustomResourceDefinition{
+					Spec: apiext.CustomResourceDefinitionSpec{
+						Versions: []apiext.CustomResourceDefinitionVersion{{
+							Name: "v1",
+							Schema: &apiext.CustomResourceValidation{
+								OpenAPIV3Schema: &apiext.JSONSchemaProps{
+									Type: "object",
+									Properties: map[string]apiext.JSONSchemaProps{
+										"replicas": {
+											Type:    "integer",
+											Default: &apiext.JSON{Raw: []byte("1")},
+										},
+										"items": {
+											Type:         "array",
+											XListType:    &listType,
+											XListMapKeys: []string{"name"},
+											Items: &apiext.JSONSchemaPropsOrArray{Schema: &apiext.JSONSchemaProps{
+												Type:     "object",
+												XMapType: &mapType,
+											}},
+										},
+									},
+								},
+							},
+						}},
+					},
+				}
+			}
+		})
+
+		It("should keep everything supported by the target", func() {
+			obj := sampleCRD()
+			Expect(crd.StripUnsupportedFeatures(obj, crd.KubeVersion{Major: 1, Minor: 17})).To(BeEmpty())
+			Expect(obj).To(Equal(sampleCRD()))
+		})
+
+		It("should remove and report features newer than the target", func() {
+			obj := sampleCRD()
+			removed := crd.StripUnsupportedFeatures(obj, crd.KubeVersion{Major: 1, Minor: 16})
+			Expect(removed).To(Equal([]string{"x-kubernetes-map-type at v1.items[*] (requires Kubernetes 1.17+)"}))
+
+			schema := obj.Spec.Versions[0].Schema.OpenAPIV3Schema
+			Expect(schema.Properties["items"].Items.Schema.XMapType).To(BeNil())
+			Expect(schema.Properties["items"].XListType).NotTo(BeNil())
+			Expect(schema.Properties["replicas"].Default).NotTo(BeNil())
+		})
+
+		It("should remove features from all levels of the schema", func() {
+			obj := sampleCRD()
+			removed := crd.StripUnsupportedFeatures(obj, crd.KubeVersion{Major: 1, Minor: 15})
+			Expect(removed).To(ConsistOf(
+				"default at v1.replicas (requires Kubernetes 1.16+)",
+				"x-kubernetes-list-type at v1.items (requires Kubernetes 1.16+)",
+				"x-kubernetes-map-type at v1.items[*] (requires Kubernetes 1.17+)",
+			))
+
+			schema := obj.Spec.Versions[0].Schema.OpenAPIV3Schema
+			Expect(schema.Properties["replicas"].Default).To(BeNil())
+			Expect(schema.Properties["items"].XListType).To(BeNil())
+			Expect(schema.Properties["items"].XListMapKeys).To(BeNil())
+		})
+
+		It("should remove features from every kind of subschema", func() {
+			withDefault := func() apiext.JSONSchemaProps {
+				return apiext.JSONSchemaProps{Type: "string", Default: &apiext.JSON{Raw: []byte(`"x"`)}}
+			}
+			dependency := withDefault()
+			schema := apiext.JSONSchemaProps{
+				Type:              "object",
+				PatternProperties: map[string]apiext.JSONSchemaProps{"^a": withDefault()},
+				Dependencies:      apiext.JSONSchemaDependencies{"b": {Schema: &dependency}},
+				Definitions:       apiext.JSONSchemaDefinitions{"c": withDefault()},
+				Properties: map[string]apiext.JSONSchemaProps{
+					"tuple": {
+						Type:            "array",
+						Items:           &apiext.JSONSchemaPropsOrArray{JSONSchemas: []apiext.JSONSchemaProps{withDefault()}},
+						AdditionalItems: &apiext.JSONSchemaPropsOrBool{Allows: true, Schema: &apiext.JSONSchemaProps{Type: "integer", Default: &apiext.JSON{Raw: []byte("1")}}},
+					},
+				},
+			}
+			obj := &apiext.CustomResourceDefinition{Spec: apiext.CustomResourceDefinitionSpec{
+				Versions: []apiext.CustomResourceDefinitionVersion{{
+					Name:   "v1",
+					Schema: &apiext.CustomResourceValidation{OpenAPIV3Schema: &schema},
+				}},
+			}}
+
+			removed := crd.StripUnsupportedFeatures(obj, crd.KubeVersion{Major: 1, Minor: 15})
+			Expect(removed).To(Equal([]string{
+				"default at v1.definitions[c] (requires Kubernetes 1.16+)",
+				"default at v1.dependencies[b] (requires Kubernetes 1.16+)",
+				"default at v1.patternProperties[^a] (requires Kubernetes 1.16+)",
+				"default at v1.tuple[*] (requires Kubernetes 1.16+)",
+				"default at v1.tuple[0] (requires Kubernetes 1.16+)",
+			}))
+			Expect(schema.PatternProperties["^a"].Default).To(BeNil())
+			Expect(schema.Dependencies["b"].Schema.Default).To(BeNil())
+			Expect(schema.Definitions["c"].Default).To(BeNil())
+			Expect(schema.Properties["tuple"].Items.JSONSchemas[0].Default).To(BeNil())
+			Expect(schema.Properties["tuple"].AdditionalItems.Schema.Default).To(BeNil())
+		})
+	})
+})
@@ -152,6 +152,25 @@ type CronJobSpec struct {
 
 	// This tests that min/max properties work
 	MinMaxProperties MinMaxObject `json:"minMaxProperties,omitempty"`
+
+	// This tests that one-of markers work
+	// +optional
+	OneOfSource *OneOfObject `json:"oneOfSource,omitempty"`
+
+	// This tests that validation markers apply to list items
+	// +kubebuilder:validation:items:MaxLength=63
+	// +kubebuilder:validation:items:Pattern=^[a-z]+$
+	// +optional
+	ItemsValidation []string `json:"itemsValidation,omitempty"`
+
+	// This tests that validation markers apply to map values
+	// +kubebuilder:validation:values:Minimum=1
+	// +optional
+	ValuesValidation map[string]int32 `json:"valuesValidation,omitempty"`
+
+	// This tests that unions work
+	// +optional
+	Union *UnionObject `json:"union,omitempty"`
 }
 
 type NestedObject struct {
@@ -169,6 +188,38 @@ type MinMaxObject struct {
 	Baz string `json:"baz,omitempty"`
 }
 
+// +kubebuilder:validation:ExactlyOneOf={configMap,secret}
+// +kubebuilder:validation:AtMostOneOf={secret,inline}
+type OneOfObject struct {
+	ConfigMap string `json:"configMap,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	Inline    string `json:"inline,omitempty"`
+}
+
+// MultiOneOfObject isn't part of the CronJob, it's for checking that several
+// union markers are applied in a fixed order.
+// +kubebuilder:validation:OneOf={configMap,secret}
+// +kubebuilder:validation:ExactlyOneOf={secret,inline}
+// +kubebuilder:validation:AtMostOneOf={configMap,inline}
+// +kubebuilder:validation:AtMostOneOf={secret,inline}
+type MultiOneOfObject struct {
+	ConfigMap string `json:"configMap,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	Inline    string `json:"inline,omitempty"`
+}
+
+type UnionObject struct {
+	// +unionDiscriminator
+	Type string `json:"type"`
+
+	// +union
+	// +optional
+	First *NestedObject `json:"first,omitempty"`
+	// +union
+	// +optional
+	Second *NestedObject `json:"second,omitempty"`
+}
+
 type RootObject struct {
 	Nested NestedObject `json:"nested"`
 }
@@ -0,0 +1,137 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"reflect"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// checkTopology verifies that the list topology of the given field's schema
+// is consistent with the type of the list's items.  The API server rejects
+// CRDs with inconsistent topologies (and server-side apply misbehaves with
+// the ones it doesn't reject), so we'd rather catch these at generation time.
+//
+// Items whose schema can't be resolved yet (e.g. recursive types) are skipped.
+func checkTopology(ctx *schemaContext, field markers.FieldInfo, props *apiext.JSONSchemaProps) {
+	if props.XListType == nil || props.Items == nil || props.Items.Schema == nil {
+		// listMapKey without listType=map is caught when applying the marker
+		return
+	}
+	items, itemsPkg := ctx.resolveSchema(*props.Items.Schema, ctx.pkg)
+	if items == nil {
+		return
+	}
+
+	listTypeErr := func(err error) {
+		ctx.pkg.AddError(markerErr(err, field.MarkerPositions, "listType", 0, field.RawField))
+	}
+
+	switch *props.XListType {
+	case "set":
+		if !isScalarSchema(items) {
+			listTypeErr(fmt.Errorf("listType=set may only be used on lists of scalar items"))
+		}
+	case "map":
+		if len(props.XListMapKeys) == 0 {
+			listTypeErr(fmt.Errorf("listType=map requires at least one listMapKey"))
+			return
+		}
+		if items.Type != "object" {
+			listTypeErr(fmt.Errorf("listType=map may only be used on lists of object items"))
+			return
+		}
+
+		itemProps := make(map[string]apiext.JSONSchemaProps)
+		required := make(map[string]bool)
+		ctx.collectProperties(items, itemsPkg, itemProps, required)
+		for i, key := range props.XListMapKeys {
+			if err := ctx.checkMapKey(key, itemProps, required, itemsPkg); err != nil {
+				ctx.pkg.AddError(markerErr(err, field.MarkerPositions, "listMapKey", i, field.RawField))
+			}
+		}
+	}
+}
+
+// checkMapKey checks that the given key of an associative list refers to a
+// scalar field of the list's items that's either required or defaulted.
+func (c *schemaContext) checkMapKey(key string, itemProps map[string]apiext.JSONSchemaProps, required map[string]bool, itemsPkg *loader.Package) error {
+	keyProp, exists := itemProps[key]
+	if !exists {
+		return fmt.Errorf("listMapKey %q is not a field of the list's items", key)
+	}
+	resolved, _ := c.resolveSchema(keyProp, itemsPkg)
+	if resolved != nil && !isScalarSchema(resolved) {
+		return fmt.Errorf("listMapKey %q must refer to a scalar field", key)
+	}
+	// defaults on the key's type get flattened into the field's schema
+	hasDefault := keyProp.Default != nil || (resolved != nil && resolved.Default != nil)
+	if !required[key] && !hasDefault {
+		return fmt.Errorf("listMapKey %q must refer to a required field, or a field with a default", key)
+	}
+	return nil
+}
+
+// resolveSchema follows references in the given schema (from the given package),
+// returning the referenced schema and the package it lives in, or nil if the
+// schema isn't (fully) known yet.
+func (c *schemaContext) resolveSchema(props apiext.JSONSchemaProps, pkg *loader.Package) (*apiext.JSONSchemaProps, *loader.Package) {
+	seen := make(map[TypeIdent]bool)
+	for props.Ref != nil && len(*props.Ref) > 0 {
+		ident, err := identFromRef(*props.Ref, pkg)
+		if err != nil || ident.Package == nil || seen[ident] {
+			return nil, nil
+		}
+		seen[ident] = true
+
+		refSchema, known := c.schemaRequester.lookupSchema(ident)
+		if !known || reflect.DeepEqual(refSchema, apiext.JSONSchemaProps{}) {
+			// not generated yet, or still a work-in-progress
+			return nil, nil
+		}
+		props, pkg = refSchema, ident.Package
+	}
+	return &props, pkg
+}
+
+// collectProperties gathers the properties of the given object schema into props,
+// including those from embedded fields, noting which ones are required.
+func (c *schemaContext) collectProperties(schema *apiext.JSONSchemaProps, pkg *loader.Package, props map[string]apiext.JSONSchemaProps, required map[string]bool) {
+	for name, prop := range schema.Properties {
+		props[name] = prop
+	}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	for _, embedded := range schema.AllOf {
+		resolved, resolvedPkg := c.resolveSchema(embedded, pkg)
+		if resolved == nil {
+			continue
+		}
+		c.collectProperties(resolved, resolvedPkg, props, required)
+	}
+}
+
+// isScalarSchema checks if the given schema describes a scalar value.
+func isScalarSchema(schema *apiext.JSONSchemaProps) bool {
+	return schema.Type != "object" && schema.Type != "array" && len(schema.Properties) == 0 && schema.Items == nil
+}
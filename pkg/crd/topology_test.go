@@ -0,0 +1,142 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var _ = Describe("Topology marker checks", func() {
+	var (
+		pkg     *loader.Package
+		cleanup func()
+		parser  *crd.Parser
+	)
+
+	BeforeEach(func() {
+		pkg, cleanup = loadFakePackage("sigs.k8s.io/controller-tools/pkg/crd/topologytest", map[string]interface{}{
+			"types.go": `
+				package topologytest
+
+				type Item struct {
+					Name string ` + "`json:\"name\"`" + `
+					Optional string ` + "`json:\"optional,omitempty\"`" + `
+					Nested Nested ` + "`json:\"nested\"`" + `
+				}
+
+				type Nested struct {
+					Value string ` + "`json:\"value\"`" + `
+				}
+
+				type Good struct {
+					// +listType=set
+					Set []string ` + "`json:\"set\"`" + `
+
+					// +listType=map
+					// +listMapKey=name
+					Map []Item ` + "`json:\"map\"`" + `
+				}
+
+				type BadSet struct {
+					// +listType=set
+					Set []Item ` + "`json:\"set\"`" + `
+				}
+
+				type BadMapItems struct {
+					// +listType=map
+					// +listMapKey=name
+					Map []string ` + "`json:\"map\"`" + `
+				}
+
+				type MissingKey struct {
+					// +listType=map
+					// +listMapKey=name
+					// +listMapKey=missing
+					Map []Item ` + "`json:\"map\"`" + `
+				}
+
+				type OptionalKey struct {
+					// +listType=map
+					// +listMapKey=optional
+					Map []Item ` + "`json:\"map\"`" + `
+				}
+
+				type NonScalarKey struct {
+					// +listType=map
+					// +listMapKey=nested
+					Map []Item ` + "`json:\"map\"`" + `
+				}
+			`,
+		})
+
+		reg := &markers.Registry{}
+		Expect(crdmarkers.Register(reg)).To(Succeed())
+		parser = &crd.Parser{
+			Collector: &markers.Collector{Registry: reg},
+			Checker:   &loader.TypeChecker{},
+		}
+		parser.NeedPackage(pkg)
+	})
+
+	AfterEach(func() {
+		cleanup()
+	})
+
+	errorsFor := func(typeName string) []string {
+		parser.NeedSchemaFor(crd.TypeIdent{Package: pkg, Name: typeName})
+		var msgs []string
+		for _, err := range pkg.Errors {
+			msgs = append(msgs, err.Msg)
+		}
+		return msgs
+	}
+
+	It("should accept consistent topologies", func() {
+		Expect(errorsFor("Good")).To(BeEmpty())
+	})
+
+	It("should reject sets of non-scalar items", func() {
+		Expect(errorsFor("BadSet")).To(ConsistOf(ContainSubstring("listType=set may only be used on lists of scalar items")))
+	})
+
+	It("should reject maps of non-object items", func() {
+		Expect(errorsFor("BadMapItems")).To(ConsistOf(ContainSubstring("listType=map may only be used on lists of object items")))
+	})
+
+	It("should reject map keys that aren't fields of the items", func() {
+		Expect(errorsFor("MissingKey")).To(ConsistOf(ContainSubstring(`listMapKey "missing" is not a field`)))
+	})
+
+	It("should reject map keys that are neither required nor defaulted", func() {
+		Expect(errorsFor("OptionalKey")).To(ConsistOf(ContainSubstring(`listMapKey "optional" must refer to a required field`)))
+	})
+
+	It("should reject map keys that aren't scalars", func() {
+		Expect(errorsFor("NonScalarKey")).To(ConsistOf(ContainSubstring(`listMapKey "nested" must refer to a scalar field`)))
+	})
+
+	It("should point errors at the offending marker", func() {
+		Expect(errorsFor("MissingKey")).To(HaveLen(1))
+		Expect(pkg.Errors[0].Pos).To(ContainSubstring("types.go:37"))
+	})
+})
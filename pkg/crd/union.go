@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// unionField is a field taking part in a union.
+type unionField struct {
+	info     markers.FieldInfo
+	jsonName string
+}
+
+// unionInfo collects the members (and discriminator) of the union in a struct,
+// as marked by the `+union` and `+unionDiscriminator` markers.
+type unionInfo struct {
+	members        []unionField
+	discriminators []unionField
+}
+
+// addField records the given field if it takes part in the union.
+func (u *unionInfo) addField(field markers.FieldInfo, jsonName string) {
+	if field.Markers.Get("union") != nil {
+		u.members = append(u.members, unionField{info: field, jsonName: jsonName})
+	}
+	if field.Markers.Get("unionDiscriminator") != nil {
+		u.discriminators = append(u.discriminators, unionField{info: field, jsonName: jsonName})
+	}
+}
+
+// applyTo adds the constraints of the union to the given struct schema.
+//
+// At most one member may be set.  If there's a discriminator, a member may
+// only be set if the discriminator names it (by its Go field name).
+func (u *unionInfo) applyTo(ctx *schemaContext, props *apiext.JSONSchemaProps) {
+	if len(u.discriminators) > 1 {
+		for _, disc := range u.discriminators[1:] {
+			ctx.pkg.AddError(markerErr(fmt.Errorf("a struct may only have one union discriminator"), disc.info.MarkerPositions, "unionDiscriminator", 0, disc.info.RawField))
+		}
+	}
+	if len(u.members) == 0 {
+		for _, disc := range u.discriminators {
+			ctx.pkg.AddError(markerErr(fmt.Errorf("union discriminator without any union members"), disc.info.MarkerPositions, "unionDiscriminator", 0, disc.info.RawField))
+		}
+		return
+	}
+
+	valid := true
+	names := make([]string, len(u.members))
+	for i, member := range u.members {
+		names[i] = member.jsonName
+		for _, req := range props.Required {
+			if req == member.jsonName {
+				ctx.pkg.AddError(markerErr(fmt.Errorf("union member %q must be optional", member.jsonName), member.info.MarkerPositions, "union", 0, member.info.RawField))
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		return
+	}
+
+	forbidden := crdmarkers.MemberPairs(names)
+	if len(u.discriminators) > 0 {
+		disc := u.discriminators[0]
+		for _, member := range u.members {
+			// a member is set, and the discriminator doesn't name it
+			nameVal, err := json.Marshal(member.info.Name)
+			if err != nil {
+				ctx.pkg.AddError(err)
+				return
+			}
+			forbidden = append(forbidden, apiext.JSONSchemaProps{
+				Required: []string{member.jsonName},
+				Not: &apiext.JSONSchemaProps{
+					Required: []string{disc.jsonName},
+					Properties: map[string]apiext.JSONSchemaProps{
+						disc.jsonName: {Enum: []apiext.JSON{{Raw: nameVal}}},
+					},
+				},
+			})
+		}
+	}
+	crdmarkers.AddForbiddenCombinations(props, forbidden...)
+}
@@ -52,6 +52,26 @@ func (Generator) Help() *markers.DefinitionHelp {
 				Summary: "specifies the target API versions of the CRD type itself to generate. Defaults to v1. ",
 				Details: "The first version listed will be assumed to be the \"default\" version and will not get a version suffix in the output filename. \n You'll need to use \"v1\" to get support for features like defaulting, along with an API server that supports it (Kubernetes 1.16+).",
 			},
+			"MaxSize": markers.DetailedHelp{
+				Summary: "specifies the maximum size, in bytes, of each generated CRD. ",
+				Details: "CRDs larger than this have their descriptions progressively trimmed, deepest and longest first, until they fit.  Generation fails for CRDs that are still too large without any descriptions. \n Size is measured as the length of the CRD serialized as JSON, as stored by `kubectl apply` in the last-applied-configuration annotation (which is limited to 262144 bytes in total).  The size of each CRD before trimming is listed in the report, if one is requested.",
+			},
+			"Report": markers.DetailedHelp{
+				Summary: "specifies the path of a report on the size and complexity of each generated CRD, relative to the output location of this generator. ",
+				Details: "The report lists the serialized size of each CRD and the schema of each of its versions, the largest fields, the maximum nesting depth, the number of properties, and the external types contributing the most to the size of each schema.  It's written as JSON if the path ends in `.json`, and as Markdown if it ends in `.md`.",
+			},
+			"InferListTypes": markers.DetailedHelp{
+				Summary: "indicates that list types should be inferred for list fields that don't have a listType marker, so that server-side apply can merge them. ",
+				Details: "Lists of scalars are treated as atomic.  Lists of objects are treated as associative lists if the field has a patchMergeKey struct tag, or the item type has listMapKey markers, as long as the keys refer to required (or defaulted) scalar fields.  Inferred list types are listed in the report, if one is requested.",
+			},
+			"FeatureSets": markers.DetailedHelp{
+				Summary: "specifies sets of feature gates to generate CRDs for. ",
+				Details: "Each set is given as `Name=GateA+GateB` (or just `Name`, for a set without any gates enabled).  One variant of each CRD is generated per set, with the set's name appended to the file name (e.g. `group_kinds-Name.yaml`).  Each variant only contains the fields, validations and enum values (see the featureGate, FeatureGated and FeatureGatedEnum markers) whose gates are enabled in the set. \n Left unspecified, a single variant containing everything is generated.",
+			},
+			"TargetKubernetesVersion": markers.DetailedHelp{
+				Summary: "specifies the oldest Kubernetes version (e.g. `1.16`) that the generated CRDs must work with. ",
+				Details: "Schema features that the target doesn't support (like defaults before 1.16, or x-kubernetes-map-type before 1.17) are removed, and each removal is listed in the report.  Without a report, generation fails instead, listing the features that would have been removed.  Features newer than the apiextensions types controller-gen is built against (like CEL validation rules and selectable fields) are never generated, so they don't need to be removed.  CRD versions that the target doesn't serve (v1 before 1.16, v1beta1 from 1.22 on) are refused. \n Left unspecified, all features are kept.",
+			},
 		},
 	}
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package yaml
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -43,6 +44,71 @@ func ToYAML(rawObj interface{}) (*yaml.Node, error) {
 	return &out, nil
 }
 
+// ToJSON converts a YAML node tree into JSON, preserving the order of
+// mapping keys (unlike decoding the node tree and then marshalling it).
+func ToJSON(root *yaml.Node) ([]byte, error) {
+	var out bytes.Buffer
+	if err := writeJSON(&out, root); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// writeJSON writes the JSON form of the given YAML node tree to out.
+func writeJSON(out *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			out.WriteString("null")
+			return nil
+		}
+		return writeJSON(out, node.Content[0])
+	case yaml.AliasNode:
+		return writeJSON(out, node.Alias)
+	case yaml.MappingNode:
+		out.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			rawKey, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			out.Write(rawKey)
+			out.WriteByte(':')
+			if err := writeJSON(out, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	case yaml.SequenceNode:
+		out.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := writeJSON(out, item); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+	case yaml.ScalarNode:
+		var val interface{}
+		if err := node.Decode(&val); err != nil {
+			return err
+		}
+		rawVal, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Errorf("unable to convert value at line %d to JSON: %w", node.Line, err)
+		}
+		out.Write(rawVal)
+	default:
+		return fmt.Errorf("unexpected node kind %v", node.Kind)
+	}
+	return nil
+}
+
 // changeAll calls the given callback for all nodes in
 // the given YAML node tree.
 func changeAll(root *yaml.Node, cb func(*yaml.Node)) {
@@ -0,0 +1,46 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("ToJSON", func() {
+	It("should preserve the order of mapping keys", func() {
+		var node yaml.Node
+		Expect(yaml.Unmarshal([]byte("zed: 1\nalpha:\n  nested: [true, null, \"x\"]\nmid: 1.5\n"), &node)).To(Succeed())
+
+		rawJSON, err := ToJSON(&node)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(rawJSON)).To(Equal(`{"zed":1,"alpha":{"nested":[true,null,"x"]},"mid":1.5}`))
+	})
+
+	It("should leave comments in the node tree alone", func() {
+		var node yaml.Node
+		Expect(yaml.Unmarshal([]byte("foo:\n  bar: 1\n# trailing\n"), &node)).To(Succeed())
+
+		_, err := ToJSON(&node)
+		Expect(err).NotTo(HaveOccurred())
+
+		var fresh yaml.Node
+		Expect(yaml.Unmarshal([]byte("foo:\n  bar: 1\n# trailing\n"), &fresh)).To(Succeed())
+		Expect(node).To(Equal(fresh))
+	})
+})
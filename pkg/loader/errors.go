@@ -37,15 +37,22 @@ type Node interface {
 // attaching it to the given AST node.  It will automatically map
 // over error lists.
 func ErrFromNode(err error, node Node) error {
+	return ErrFromPos(err, node.Pos())
+}
+
+// ErrFromPos returns the given error, with additional information
+// attaching it to the given position.  It will automatically map
+// over error lists.
+func ErrFromPos(err error, pos token.Pos) error {
 	if asList, isList := err.(ErrList); isList {
 		resList := make(ErrList, len(asList))
 		for i, baseErr := range asList {
-			resList[i] = ErrFromNode(baseErr, node)
+			resList[i] = ErrFromPos(baseErr, pos)
 		}
 		return resList
 	}
 	return PositionedError{
-		Pos:   node.Pos(),
+		Pos:   pos,
 		error: err,
 	}
 }
@@ -97,11 +97,13 @@ func (p *Package) NeedTypesInfo() {
 }
 
 // NeedSyntax indicates that a parsed AST is needed for this package.
-// Actual ASTs can be accessed via the Syntax field.
+// Actual ASTs can be accessed via the Syntax field, and positions within
+// them resolved using the Fset field.
 func (p *Package) NeedSyntax() {
 	if p.Syntax != nil {
 		return
 	}
+	p.Fset = p.loader.cfg.Fset
 	out := make([]*ast.File, len(p.CompiledGoFiles))
 	var wg sync.WaitGroup
 	wg.Add(len(p.CompiledGoFiles))
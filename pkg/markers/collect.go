@@ -31,8 +31,9 @@ import (
 type Collector struct {
 	*Registry
 
-	byPackage map[string]map[ast.Node]MarkerValues
-	mu        sync.Mutex
+	byPackage          map[string]map[ast.Node]MarkerValues
+	positionsByPackage map[string]map[ast.Node]MarkerPositions
+	mu                 sync.Mutex
 }
 
 // MarkerValues are all the values for some set of markers.
@@ -48,6 +49,21 @@ func (v MarkerValues) Get(name string) interface{} {
 	return vals[0]
 }
 
+// MarkerPositions are the source positions of the marker comments that
+// produced some set of MarkerValues.  Each position corresponds to the
+// value at the same index in the MarkerValues.
+type MarkerPositions map[string][]token.Pos
+
+// Get fetches the position of the i-th value for the given marker,
+// returning token.NoPos if no such value is available.
+func (p MarkerPositions) Get(name string, i int) token.Pos {
+	positions := p[name]
+	if i < 0 || i >= len(positions) {
+		return token.NoPos
+	}
+	return positions[i]
+}
+
 func (c *Collector) init() {
 	if c.Registry == nil {
 		c.Registry = &Registry{}
@@ -55,6 +71,9 @@ func (c *Collector) init() {
 	if c.byPackage == nil {
 		c.byPackage = make(map[string]map[ast.Node]MarkerValues)
 	}
+	if c.positionsByPackage == nil {
+		c.positionsByPackage = make(map[string]map[ast.Node]MarkerPositions)
+	}
 }
 
 // MarkersInPackage computes the marker values by node for the given package.  Results
@@ -84,7 +103,7 @@ func (c *Collector) MarkersInPackage(pkg *loader.Package) (map[ast.Node]MarkerVa
 
 	pkg.NeedSyntax()
 	nodeMarkersRaw := c.associatePkgMarkers(pkg)
-	markers, err := c.parseMarkersInPackage(nodeMarkersRaw)
+	markers, positions, err := c.parseMarkersInPackage(nodeMarkersRaw)
 	if err != nil {
 		return nil, err
 	}
@@ -92,14 +111,30 @@ func (c *Collector) MarkersInPackage(pkg *loader.Package) (map[ast.Node]MarkerVa
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.byPackage[pkg.ID] = markers
+	c.positionsByPackage[pkg.ID] = positions
 
 	return markers, nil
 }
 
-// parseMarkersInPackage parses the given raw marker comments into output values using the registry.
-func (c *Collector) parseMarkersInPackage(nodeMarkersRaw map[ast.Node][]markerComment) (map[ast.Node]MarkerValues, error) {
+// MarkerPositionsInPackage computes the positions of the marker values returned by
+// MarkersInPackage for the given package, by node.  Like MarkersInPackage, results
+// are cached by package ID.
+func (c *Collector) MarkerPositionsInPackage(pkg *loader.Package) (map[ast.Node]MarkerPositions, error) {
+	if _, err := c.MarkersInPackage(pkg); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.positionsByPackage[pkg.ID], nil
+}
+
+// parseMarkersInPackage parses the given raw marker comments into output values using the registry,
+// also returning the position of each value.
+func (c *Collector) parseMarkersInPackage(nodeMarkersRaw map[ast.Node][]markerComment) (map[ast.Node]MarkerValues, map[ast.Node]MarkerPositions, error) {
 	var errors []error
 	nodeMarkerValues := make(map[ast.Node]MarkerValues)
+	nodeMarkerPositions := make(map[ast.Node]MarkerPositions)
 	for node, markersRaw := range nodeMarkersRaw {
 		var target TargetType
 		switch node.(type) {
@@ -111,6 +146,7 @@ func (c *Collector) parseMarkersInPackage(nodeMarkersRaw map[ast.Node][]markerCo
 			target = DescribesType
 		}
 		markerVals := make(map[string][]interface{})
+		markerPositions := make(MarkerPositions)
 		for _, markerRaw := range markersRaw {
 			markerText := markerRaw.Text()
 			def := c.Registry.Lookup(markerText, target)
@@ -123,11 +159,13 @@ func (c *Collector) parseMarkersInPackage(nodeMarkersRaw map[ast.Node][]markerCo
 				continue
 			}
 			markerVals[def.Name] = append(markerVals[def.Name], val)
+			markerPositions[def.Name] = append(markerPositions[def.Name], markerRaw.Pos())
 		}
 		nodeMarkerValues[node] = markerVals
+		nodeMarkerPositions[node] = markerPositions
 	}
 
-	return nodeMarkerValues, loader.MaybeErrList(errors)
+	return nodeMarkerValues, nodeMarkerPositions, loader.MaybeErrList(errors)
 }
 
 // associatePkgMarkers associates markers with AST nodes in the given package.
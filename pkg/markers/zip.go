@@ -105,6 +105,8 @@ type FieldInfo struct {
 
 	// Markers are all registered markers associated with this field.
 	Markers MarkerValues
+	// MarkerPositions are the positions of the values in Markers.
+	MarkerPositions MarkerPositions
 
 	// RawField is the raw, underlying field AST object that this field represents.
 	RawField *ast.Field
@@ -120,6 +122,8 @@ type TypeInfo struct {
 
 	// Markers are all registered markers associated with the type.
 	Markers MarkerValues
+	// MarkerPositions are the positions of the values in Markers.
+	MarkerPositions MarkerPositions
 
 	// Fields are all the fields associated with the type, if it's a struct.
 	// (if not, Fields will be nil).
@@ -151,6 +155,10 @@ func EachType(col *Collector, pkg *loader.Package, cb TypeCallback) error {
 	if err != nil {
 		return err
 	}
+	positions, err := col.MarkerPositionsInPackage(pkg)
+	if err != nil {
+		return err
+	}
 
 	loader.EachType(pkg, func(file *ast.File, decl *ast.GenDecl, spec *ast.TypeSpec) {
 		var fields []FieldInfo
@@ -158,32 +166,35 @@ func EachType(col *Collector, pkg *loader.Package, cb TypeCallback) error {
 			for _, field := range structSpec.Fields.List {
 				for _, name := range field.Names {
 					fields = append(fields, FieldInfo{
-						Name:     name.Name,
-						Doc:      extractDoc(field, nil),
-						Tag:      loader.ParseAstTag(field.Tag),
-						Markers:  markers[field],
-						RawField: field,
+						Name:            name.Name,
+						Doc:             extractDoc(field, nil),
+						Tag:             loader.ParseAstTag(field.Tag),
+						Markers:         markers[field],
+						MarkerPositions: positions[field],
+						RawField:        field,
 					})
 				}
 				if field.Names == nil {
 					fields = append(fields, FieldInfo{
-						Doc:      extractDoc(field, nil),
-						Tag:      loader.ParseAstTag(field.Tag),
-						Markers:  markers[field],
-						RawField: field,
+						Doc:             extractDoc(field, nil),
+						Tag:             loader.ParseAstTag(field.Tag),
+						Markers:         markers[field],
+						MarkerPositions: positions[field],
+						RawField:        field,
 					})
 				}
 			}
 		}
 
 		cb(&TypeInfo{
-			Name:    spec.Name.Name,
-			Markers: markers[spec],
-			Doc:     extractDoc(spec, decl),
-			Fields:  fields,
-			RawDecl: decl,
-			RawSpec: spec,
-			RawFile: file,
+			Name:            spec.Name.Name,
+			Markers:         markers[spec],
+			MarkerPositions: positions[spec],
+			Doc:             extractDoc(spec, decl),
+			Fields:          fields,
+			RawDecl:         decl,
+			RawSpec:         spec,
+			RawFile:         file,
 		})
 	})
 
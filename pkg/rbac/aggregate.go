@@ -0,0 +1,220 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var (
+	// AggregateDefinition is a marker for generating ClusterRoles that get
+	// aggregated into the default user-facing roles.
+	AggregateDefinition = markers.Must(markers.MakeDefinition("kubebuilder:rbac:aggregate", markers.DescribesPackage, Aggregate{}))
+)
+
+// aggregateVerbs are the verbs granted on resources and their subresources
+// by each of the user-facing roles.
+var aggregateVerbs = map[string]struct {
+	resources, subresources []string
+}{
+	"view":  {resources: []string{"get", "list", "watch"}, subresources: []string{"get"}},
+	"edit":  {resources: []string{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"}, subresources: []string{"get", "patch", "update"}},
+	"admin": {resources: []string{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"}, subresources: []string{"get", "patch", "update"}},
+}
+
+// +controllertools:marker:generateHelp:category=RBAC
+
+// Aggregate specifies that the default user-facing ClusterRoles (admin, edit
+// and/or view) should be granted access to the kinds in this package.
+//
+// A ClusterRole is generated for each role, labeled so that it's aggregated into
+// that role.  View grants read-only access, while edit and admin grant full
+// access.  The status and scale subresources are covered as well, if the kind
+// has them.
+type Aggregate struct {
+	// To specifies the roles to aggregate into (admin, edit or view).
+	To []string
+	// Kinds specifies the kinds in this package to grant access to.
+	//
+	// If not set, all kinds in the package are covered.
+	Kinds []string `marker:",optional"`
+}
+
+// GenerateAggregatedRoles generates a ClusterRole for each of the default
+// user-facing roles that's aggregated into using the Aggregate marker, named
+// after the given role name (with an "-aggregate-to-<role>" suffix).
+//
+// The order of the objs in the returned slice is stable.
+func GenerateAggregatedRoles(ctx *genall.GenerationContext, roleName string) ([]interface{}, error) {
+	kinds := newKindParser(ctx)
+	return aggregatedRolesFor(roleName, aggregatedRules(kinds, collectMarkers(ctx, kinds).aggregates)), nil
+}
+
+// aggregateMarker is an Aggregate marker, along with its position and the
+// package it's in.
+type aggregateMarker struct {
+	aggregate Aggregate
+	pos       token.Pos
+	pkg       *loader.Package
+}
+
+// aggregatedRules collects the rules for each of the default user-facing
+// roles aggregated into using the given Aggregate markers, by role, with the
+// positions of the markers as their sources.
+func aggregatedRules(kinds *kindParser, aggregates []aggregateMarker) map[string]ruleSet {
+	rulesByRole := make(map[string]ruleSet)
+	addRule := func(role string, rule *Rule, source token.Position) {
+		if _, ok := rulesByRole[role]; !ok {
+			rulesByRole[role] = make(ruleSet)
+		}
+		rulesByRole[role].add(rule, source)
+	}
+
+	aggregatesByPkg := make(map[*loader.Package][]aggregateMarker)
+	for _, marker := range aggregates {
+		aggregatesByPkg[marker.pkg] = append(aggregatesByPkg[marker.pkg], marker)
+	}
+
+	for _, root := range kinds.ctx.Roots {
+		pkgAggregates := aggregatesByPkg[root]
+		if len(pkgAggregates) == 0 {
+			continue
+		}
+
+		parser, kubeKinds := kinds.kinds()
+		groupVersion, hasGroupVersion := parser.GroupVersions[root]
+		if !hasGroupVersion {
+			root.AddError(fmt.Errorf("cannot aggregate access to kinds in a package without a +groupName marker"))
+			continue
+		}
+
+		// find the kinds declared in this package
+		pkgKinds := make(map[string]schema.GroupKind)
+		for groupKind := range kubeKinds {
+			if groupKind.Group != groupVersion.Group || parser.Types[crd.TypeIdent{Package: root, Name: groupKind.Kind}] == nil {
+				continue
+			}
+			pkgKinds[groupKind.Kind] = groupKind
+		}
+
+		for _, marker := range pkgAggregates {
+			aggregate := marker.aggregate
+			aggregateKinds := aggregate.Kinds
+			if len(aggregateKinds) == 0 {
+				for kind := range pkgKinds {
+					aggregateKinds = append(aggregateKinds, kind)
+				}
+			}
+
+			var resources, subresources []string
+			for _, kind := range aggregateKinds {
+				groupKind, known := pkgKinds[kind]
+				if !known {
+					root.AddError(fmt.Errorf("unknown kind %q in package %s for aggregated roles", kind, root.PkgPath))
+					continue
+				}
+				parser.NeedCRDFor(groupKind, nil)
+				kindCRD, generated := parser.CustomResourceDefinitions[groupKind]
+				if !generated {
+					continue
+				}
+				resources = append(resources, kindCRD.Spec.Names.Plural)
+				for _, ver := range kindCRD.Spec.Versions {
+					if ver.Subresources == nil {
+						continue
+					}
+					if ver.Subresources.Status != nil {
+						subresources = append(subresources, kindCRD.Spec.Names.Plural+"/status")
+					}
+					if ver.Subresources.Scale != nil {
+						subresources = append(subresources, kindCRD.Spec.Names.Plural+"/scale")
+					}
+				}
+			}
+
+			for _, role := range aggregate.To {
+				verbs, knownRole := aggregateVerbs[role]
+				if !knownRole {
+					root.AddError(fmt.Errorf("unknown role %q to aggregate to (must be one of admin, edit or view)", role))
+					continue
+				}
+				if len(resources) > 0 {
+					addRule(role, &Rule{
+						Groups:    []string{groupVersion.Group},
+						Resources: resources,
+						Verbs:     verbs.resources,
+					}, root.Fset.Position(marker.pos))
+				}
+				if len(subresources) > 0 {
+					addRule(role, &Rule{
+						Groups:    []string{groupVersion.Group},
+						Resources: subresources,
+						Verbs:     verbs.subresources,
+					}, root.Fset.Position(marker.pos))
+				}
+			}
+		}
+	}
+
+	return rulesByRole
+}
+
+// aggregatedRoleName returns the name of the ClusterRole aggregated into the
+// given user-facing role.
+func aggregatedRoleName(roleName, role string) string {
+	return roleName + "-aggregate-to-" + role
+}
+
+// aggregatedRolesFor generates the ClusterRoles (named after the given role
+// name) for the given rules, by user-facing role, ordered by role.
+func aggregatedRolesFor(roleName string, rulesByRole map[string]ruleSet) []interface{} {
+	var roles []string
+	for role := range rulesByRole {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	var objs []interface{}
+	for _, role := range roles {
+		objs = append(objs, rbacv1.ClusterRole{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "ClusterRole",
+				APIVersion: rbacv1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: aggregatedRoleName(roleName, role),
+				Labels: map[string]string{
+					"rbac.authorization.k8s.io/aggregate-to-" + role: "true",
+				},
+			},
+			Rules: rulesByRole[role].policyRules(),
+		})
+	}
+
+	return objs
+}
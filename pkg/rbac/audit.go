@@ -0,0 +1,269 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+// AuditEntry is a single verb on a single resource, along with where it's
+// granted by RBAC markers and where it's used in the code.
+type AuditEntry struct {
+	Group    string
+	Resource string
+	Verb     string
+
+	// GrantedAt holds the positions of the RBAC markers granting this permission.
+	GrantedAt []token.Position
+	// UsedAt holds the positions of the calls needing this permission.
+	UsedAt []token.Position
+}
+
+// AuditReport compares the permissions granted by RBAC markers with the ones
+// used by the controller-runtime client calls and watches in the code.
+type AuditReport struct {
+	// Ungranted are permissions used in the code, but not granted by any marker.
+	Ungranted []AuditEntry
+	// Unused are permissions granted by markers, but not used in the code.
+	Unused []AuditEntry
+	// Used are permissions both granted by markers and used in the code.
+	Used []AuditEntry
+}
+
+// permission identifies a verb on a resource.  Granted permissions may
+// contain wildcards.
+type permission struct {
+	group, resource, verb string
+}
+
+// covers checks if this (granted) permission covers the given (used) one.
+func (p permission) covers(other permission) bool {
+	return (p.group == "*" || p.group == other.group) &&
+		(p.resource == "*" || p.resource == other.resource) &&
+		(p.verb == "*" || p.verb == other.verb)
+}
+
+// permissionsFor expands the given rule into the individual permissions it
+// contains.  Non-resource URLs aren't included.
+func permissionsFor(rule *Rule) []permission {
+	var perms []permission
+	for _, group := range rule.Groups {
+		if group == "core" {
+			group = ""
+		}
+		for _, resource := range rule.Resources {
+			for _, verb := range rule.Verbs {
+				perms = append(perms, permission{group: group, resource: resource, verb: verb})
+			}
+		}
+	}
+	return perms
+}
+
+// Audit compares the permissions granted by the RBAC markers in the root
+// packages with the ones needed by the code, as inferred by InferRules.
+//
+// Permissions are compared individually per verb and resource.  Wildcards in
+// markers are taken into account, but resource names and namespaces aren't,
+// since which objects are accessed can't be determined statically: a marker
+// restricted to some names or a namespace counts as granting the permission.
+// Likewise, rules are audited together, whichever role they belong to.
+// Rules for non-resource URLs aren't audited.
+func Audit(ctx *genall.GenerationContext) *AuditReport {
+	return auditUses(collectMarkers(ctx, newKindParser(ctx)).rules, inferUses(ctx))
+}
+
+// auditUses audits the given rules from RBAC markers against the given
+// inferred rules, as per Audit.
+func auditUses(rules []ruleMarker, uses []ruleUse) *AuditReport {
+	granted := make(map[permission]*AuditEntry)
+	var grantedOrder []permission
+	for _, marker := range rules {
+		for _, perm := range permissionsFor(marker.rule) {
+			entry, known := granted[perm]
+			if !known {
+				entry = &AuditEntry{Group: perm.group, Resource: perm.resource, Verb: perm.verb}
+				granted[perm] = entry
+				grantedOrder = append(grantedOrder, perm)
+			}
+			entry.GrantedAt = append(entry.GrantedAt, marker.position())
+		}
+	}
+
+	ungranted := make(map[permission]*AuditEntry)
+	for _, use := range uses {
+		for _, perm := range permissionsFor(use.rule) {
+			covered := false
+			for _, grantedPerm := range grantedOrder {
+				if grantedPerm.covers(perm) {
+					granted[grantedPerm].UsedAt = append(granted[grantedPerm].UsedAt, use.pos)
+					covered = true
+				}
+			}
+			if covered {
+				continue
+			}
+			entry, known := ungranted[perm]
+			if !known {
+				entry = &AuditEntry{Group: perm.group, Resource: perm.resource, Verb: perm.verb}
+				ungranted[perm] = entry
+			}
+			entry.UsedAt = append(entry.UsedAt, use.pos)
+		}
+	}
+
+	report := &AuditReport{}
+	for _, entry := range ungranted {
+		report.Ungranted = append(report.Ungranted, *entry)
+	}
+	for _, entry := range granted {
+		if len(entry.UsedAt) == 0 {
+			report.Unused = append(report.Unused, *entry)
+		} else {
+			report.Used = append(report.Used, *entry)
+		}
+	}
+	sortAuditEntries(report.Ungranted)
+	sortAuditEntries(report.Unused)
+	sortAuditEntries(report.Used)
+	return report
+}
+
+// sortAuditEntries sorts the given entries by group, resource and verb, and
+// the positions in each entry.
+func sortAuditEntries(entries []AuditEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Group != entries[j].Group {
+			return entries[i].Group < entries[j].Group
+		}
+		if entries[i].Resource != entries[j].Resource {
+			return entries[i].Resource < entries[j].Resource
+		}
+		return entries[i].Verb < entries[j].Verb
+	})
+	for i := range entries {
+		entries[i].GrantedAt = sortPositions(entries[i].GrantedAt)
+		entries[i].UsedAt = sortPositions(entries[i].UsedAt)
+	}
+}
+
+// sortPositions sorts the given positions, removing duplicates.
+func sortPositions(positions []token.Position) []token.Position {
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Filename != positions[j].Filename {
+			return positions[i].Filename < positions[j].Filename
+		}
+		if positions[i].Line != positions[j].Line {
+			return positions[i].Line < positions[j].Line
+		}
+		return positions[i].Column < positions[j].Column
+	})
+	var res []token.Position
+	for i, pos := range positions {
+		if i > 0 && pos == positions[i-1] {
+			continue
+		}
+		res = append(res, pos)
+	}
+	return res
+}
+
+// WriteMarkdown writes this report out as a Markdown document, with a table
+// for each of the ungranted, unused and used permissions.
+//
+// Source positions are written relative to the current directory, if possible.
+func (r *AuditReport) WriteMarkdown(out io.Writer) error {
+	var buf strings.Builder
+	buf.WriteString("# RBAC Audit\n")
+
+	writeTable := func(title, description string, entries []AuditEntry, granted, used bool) {
+		fmt.Fprintf(&buf, "\n## %s\n\n%s\n\n", title, description)
+		if len(entries) == 0 {
+			buf.WriteString("None.\n")
+			return
+		}
+		buf.WriteString("| Group | Resource | Verb |")
+		if granted {
+			buf.WriteString(" Granted At |")
+		}
+		if used {
+			buf.WriteString(" Used At |")
+		}
+		buf.WriteString("\n|---|---|---|")
+		if granted {
+			buf.WriteString("---|")
+		}
+		if used {
+			buf.WriteString("---|")
+		}
+		buf.WriteString("\n")
+		for _, entry := range entries {
+			group := entry.Group
+			if group == "" {
+				group = "core"
+			}
+			fmt.Fprintf(&buf, "| %s | %s | %s |", group, entry.Resource, entry.Verb)
+			if granted {
+				fmt.Fprintf(&buf, " %s |", displayPositions(entry.GrantedAt))
+			}
+			if used {
+				fmt.Fprintf(&buf, " %s |", displayPositions(entry.UsedAt))
+			}
+			buf.WriteString("\n")
+		}
+	}
+
+	writeTable("Used But Not Granted", "These permissions are needed by the code, but not granted by any RBAC marker.", r.Ungranted, false, true)
+	writeTable("Granted But Unused", "These permissions are granted by RBAC markers, but not needed by any code that could be analyzed.", r.Unused, true, false)
+	writeTable("Granted And Used", "These permissions are granted by RBAC markers and needed by the code.", r.Used, true, true)
+
+	_, err := io.WriteString(out, buf.String())
+	return err
+}
+
+// displayPositions formats the given source positions for the report.
+func displayPositions(positions []token.Position) string {
+	cwd, err := os.Getwd()
+	displayed := make([]string, len(positions))
+	for i, pos := range positions {
+		if err == nil {
+			if relPath, relErr := filepath.Rel(cwd, pos.Filename); relErr == nil {
+				pos.Filename = relPath
+			}
+		}
+		displayed[i] = pos.String()
+	}
+	return strings.Join(displayed, ", ")
+}
+
+// writeAudit writes the given audit report out to the given file.
+func writeAudit(ctx *genall.GenerationContext, itemPath string, report *AuditReport) error {
+	out, err := ctx.Open(nil, itemPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return report.WriteMarkdown(out)
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+// PermissionEntry is a single resource (or non-resource URL) in a generated
+// rule, along with where the rule comes from.
+type PermissionEntry struct {
+	// RoleName is the name of the ClusterRole or Role containing the rule.
+	RoleName string
+	// Namespace is the namespace of the Role containing the rule, or empty
+	// for a ClusterRole.
+	Namespace string
+
+	Group         string
+	Resource      string
+	Subresource   string
+	ResourceNames []string
+	// URL is the non-resource URL, for entries that aren't about a resource.
+	URL   string
+	Verbs []string
+
+	// Sources holds the positions of the RBAC markers (and, if inferring
+	// rules, calls) contributing to the rule.
+	Sources []token.Position
+}
+
+// PermissionsDoc documents all the rules generated for a set of roles.
+type PermissionsDoc struct {
+	Entries []PermissionEntry
+}
+
+// Permissions documents the rules generated from the RBAC markers in the root
+// packages (plus those inferred from the code, if infer is set), the same way
+// GenerateRoles and GenerateAggregatedRoles do for the given role name.
+//
+// There's an entry for each group and resource (or non-resource URL) in each
+// rule.  Entries are ordered by role: the roles named after the given role
+// name, the aggregated roles, then the separately named roles, by name; each
+// role's entries are ordered by namespace, then by rule.  Rules from markers
+// that can't be generated are skipped.
+func Permissions(ctx *genall.GenerationContext, roleName string, infer bool) *PermissionsDoc {
+	var uses []ruleUse
+	if infer {
+		uses = inferUses(ctx)
+	}
+	kinds := newKindParser(ctx)
+	collected := collectMarkers(ctx, kinds)
+	grouped, err := groupRules(roleName, collected.rules, uses)
+	if err != nil {
+		// inferred rules are always valid, and errors in markers are reported
+		// at their positions
+		return &PermissionsDoc{}
+	}
+	return documentPermissions(roleName, grouped, aggregatedRules(kinds, collected.aggregates))
+}
+
+// documentPermissions documents the given grouped rules, and the rules of the
+// roles aggregated into the user-facing roles, as per Permissions.
+func documentPermissions(roleName string, grouped groupedRules, aggregatedByRole map[string]ruleSet) *PermissionsDoc {
+	doc := &PermissionsDoc{}
+	addRoles := func(name string, rulesByNS map[string]ruleSet) {
+		var namespaces []string
+		for ns := range rulesByNS {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+
+		for _, ns := range namespaces {
+			for _, merged := range rulesByNS[ns].sorted() {
+				doc.addRule(name, ns, merged)
+			}
+		}
+	}
+
+	if rulesByNS, ok := grouped[roleName]; ok {
+		addRoles(roleName, rulesByNS)
+	}
+
+	var roles []string
+	for role := range aggregatedByRole {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		addRoles(aggregatedRoleName(roleName, role), map[string]ruleSet{"": aggregatedByRole[role]})
+	}
+
+	var names []string
+	for name := range grouped {
+		names = append(names, name)
+	}
+	for _, name := range otherRoleNames(roleName, names) {
+		addRoles(name, grouped[name])
+	}
+	return doc
+}
+
+// addRule adds an entry for each group and resource (or non-resource URL) in
+// the given merged rule.
+func (d *PermissionsDoc) addRule(roleName, namespace string, merged *sourcedRule) {
+	base := PermissionEntry{
+		RoleName:      roleName,
+		Namespace:     namespace,
+		ResourceNames: merged.rule.ResourceNames,
+		Verbs:         merged.rule.Verbs,
+		Sources:       sortPositions(merged.sources),
+	}
+	for _, group := range merged.rule.Groups {
+		for _, resource := range merged.rule.Resources {
+			entry := base
+			entry.Group = group
+			parts := strings.SplitN(resource, "/", 2)
+			entry.Resource = parts[0]
+			if len(parts) == 2 {
+				entry.Subresource = parts[1]
+			}
+			d.Entries = append(d.Entries, entry)
+		}
+	}
+	for _, url := range merged.rule.URLs {
+		entry := base
+		entry.URL = url
+		d.Entries = append(d.Entries, entry)
+	}
+}
+
+// WriteMarkdown writes these permissions out as a Markdown document, with a
+// table row for each entry.
+//
+// Source positions are written relative to the current directory, if possible.
+func (d *PermissionsDoc) WriteMarkdown(out io.Writer) error {
+	var buf strings.Builder
+	buf.WriteString("# RBAC Permissions\n\n")
+	if len(d.Entries) == 0 {
+		buf.WriteString("None.\n")
+		_, err := io.WriteString(out, buf.String())
+		return err
+	}
+
+	buf.WriteString("| Role | Scope | API Group | Resource | Subresource | Resource Names | Verbs | Sources |\n")
+	buf.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, entry := range d.Entries {
+		scope := "cluster"
+		if entry.Namespace != "" {
+			scope = "namespace " + entry.Namespace
+		}
+		group, resource := entry.Group, entry.Resource
+		if entry.URL != "" {
+			group, resource = "", entry.URL+" (non-resource URL)"
+		} else if group == "" {
+			group = "core"
+		}
+		fmt.Fprintf(&buf, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			entry.RoleName, scope, group, resource, entry.Subresource,
+			strings.Join(entry.ResourceNames, ", "), strings.Join(entry.Verbs, ", "),
+			displayPositions(entry.Sources))
+	}
+
+	_, err := io.WriteString(out, buf.String())
+	return err
+}
+
+// writeDocs writes the given permissions documentation out to the given file.
+func writeDocs(ctx *genall.GenerationContext, itemPath string, doc *PermissionsDoc) error {
+	out, err := ctx.Open(nil, itemPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return doc.WriteMarkdown(out)
+}
@@ -0,0 +1,471 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/gobuffalo/flect"
+
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+const (
+	clientPkgPath  = "sigs.k8s.io/controller-runtime/pkg/client"
+	builderPkgPath = "sigs.k8s.io/controller-runtime/pkg/builder"
+	sourcePkgPath  = "sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// readVerbs are the verbs needed to read objects.  Reads go through the
+// manager's cache by default, which needs to list and watch to stay in sync.
+var readVerbs = []string{"get", "list", "watch"}
+
+// clientCalls describes the controller-runtime client methods we infer rules
+// from: the index of the argument holding the object, and the verbs needed.
+var clientCalls = map[string]struct {
+	objArg int
+	verbs  []string
+}{
+	"Get":         {objArg: 2, verbs: readVerbs},
+	"List":        {objArg: 1, verbs: []string{"list", "watch"}},
+	"Create":      {objArg: 1, verbs: []string{"create"}},
+	"Update":      {objArg: 1, verbs: []string{"update"}},
+	"Patch":       {objArg: 1, verbs: []string{"patch"}},
+	"Delete":      {objArg: 1, verbs: []string{"delete"}},
+	"DeleteAllOf": {objArg: 1, verbs: []string{"deletecollection"}},
+}
+
+// subresourceCalls describes the methods of the client's subresource readers
+// and writers, like clientCalls.
+var subresourceCalls = map[string]struct {
+	objArg int
+	verbs  []string
+}{
+	"Get":    {objArg: 1, verbs: []string{"get"}},
+	"Create": {objArg: 1, verbs: []string{"create"}},
+	"Update": {objArg: 1, verbs: []string{"update"}},
+	"Patch":  {objArg: 1, verbs: []string{"patch"}},
+}
+
+// clientReceivers are the client interfaces whose methods act on objects
+// themselves, and subresourceReceivers the ones whose methods act on a
+// subresource of objects.  StatusWriter is what older versions of
+// controller-runtime return from the client's Status method.
+var (
+	clientReceivers = map[string]bool{
+		"Reader": true,
+		"Writer": true,
+		"Client": true,
+	}
+	subresourceReceivers = map[string]bool{
+		"StatusWriter":      true,
+		"SubResourceReader": true,
+		"SubResourceWriter": true,
+		"SubResourceClient": true,
+	}
+)
+
+// builderCalls are the methods of the controller builder that set up watches
+// (and thus need read access) on the type passed as their first argument.
+var builderCalls = map[string]bool{
+	"For":     true,
+	"Owns":    true,
+	"Watches": true,
+}
+
+// InferRules statically analyzes the function bodies in the root packages for
+// calls to the controller-runtime client and controller builder, returning the
+// rules needed to make those calls (in the form of Rules for the ClusterRole).
+//
+// Reads from the client, as well as the types passed to the builder's For,
+// Owns and Watches, need get, list and watch.  Calls through the client's
+// subresource readers and writers are granted on the subresource: the one
+// passed to the client's SubResource method (if it's a constant), or status
+// otherwise.  Methods of anything else in the client package are ignored.
+//
+// Objects are resolved to their group using the GroupName constant or the
+// +groupName marker in the object type's package, and to their resource
+// using the +kubebuilder:resource marker on the type, falling back to the
+// lowercase plural of the kind.  Calls whose objects can't be resolved
+// statically (e.g. unstructured objects, or objects passed in as interfaces)
+// are skipped, and still need explicit RBAC markers.
+func InferRules(ctx *genall.GenerationContext) []*Rule {
+	var rules []*Rule
+	for _, use := range inferUses(ctx) {
+		rules = append(rules, use.rule)
+	}
+	return rules
+}
+
+// ruleUse is a rule inferred from a call in the code.
+type ruleUse struct {
+	rule *Rule
+	// pos is the position of the call.
+	pos token.Position
+}
+
+// inferUses finds the rules needed by the calls in the root packages, as per InferRules.
+func inferUses(ctx *genall.GenerationContext) []ruleUse {
+	inferrer := &ruleInferrer{
+		collector: ctx.Collector,
+		checker:   &loader.TypeChecker{},
+		packages:  make(map[string]*loader.Package),
+	}
+	for _, root := range ctx.Roots {
+		inferrer.inferFrom(root)
+	}
+	return inferrer.uses
+}
+
+// ruleInferrer collects inferred rules across the root packages.
+type ruleInferrer struct {
+	collector *markers.Collector
+	// checker type-checks the packages used by the roots.
+	checker *loader.TypeChecker
+	// packages holds the roots and the packages used by them, indexed by
+	// non-vendored package path.
+	packages map[string]*loader.Package
+	uses     []ruleUse
+}
+
+// inferFrom collects rules from the function bodies of the given root package.
+func (i *ruleInferrer) inferFrom(root *loader.Package) {
+	info := i.typeCheckBodies(root)
+
+	for _, file := range root.Syntax {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, isCall := node.(*ast.CallExpr)
+			if !isCall {
+				return true
+			}
+			sel, isSel := call.Fun.(*ast.SelectorExpr)
+			if !isSel {
+				return true
+			}
+			method, isFunc := info.Uses[sel.Sel].(*types.Func)
+			if !isFunc || method.Pkg() == nil {
+				return true
+			}
+			pos := root.Fset.Position(sel.Sel.Pos())
+
+			switch loader.NonVendorPath(method.Pkg().Path()) {
+			case clientPkgPath:
+				switch recv := receiverName(method); {
+				case clientReceivers[recv]:
+					clientCall, known := clientCalls[method.Name()]
+					if !known || len(call.Args) <= clientCall.objArg {
+						return true
+					}
+					i.addRule(pos, info.TypeOf(call.Args[clientCall.objArg]), "", clientCall.verbs)
+				case subresourceReceivers[recv]:
+					subresourceCall, known := subresourceCalls[method.Name()]
+					if !known || len(call.Args) <= subresourceCall.objArg {
+						return true
+					}
+					subresource, resolved := subresourceOf(info, sel.X)
+					if !resolved {
+						return true
+					}
+					i.addRule(pos, info.TypeOf(call.Args[subresourceCall.objArg]), "/"+subresource, subresourceCall.verbs)
+				}
+			case builderPkgPath:
+				if !builderCalls[method.Name()] || len(call.Args) == 0 {
+					return true
+				}
+				i.addRule(pos, watchedType(info, call.Args[0]), "", readVerbs)
+			}
+			return true
+		})
+	}
+}
+
+// receiverName returns the name of the (named) type the given method is
+// declared on, e.g. the client interface it's part of.
+func receiverName(method *types.Func) string {
+	recv := method.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return ""
+	}
+	recvType := recv.Type()
+	if ptr, isPtr := recvType.(*types.Pointer); isPtr {
+		recvType = ptr.Elem()
+	}
+	named, isNamed := recvType.(*types.Named)
+	if !isNamed {
+		return ""
+	}
+	return named.Obj().Name()
+}
+
+// subresourceOf returns the subresource acted on by a subresource reader or
+// writer, given the expression the method is called on.
+//
+// For the client's SubResource method, that's its argument, if it's a
+// constant.  Anything else is assumed to come from the client's Status
+// method, which is by far the most common.
+func subresourceOf(info *types.Info, recvExpr ast.Expr) (string, bool) {
+	call, isCall := recvExpr.(*ast.CallExpr)
+	if !isCall {
+		return "status", true
+	}
+	sel, isSel := call.Fun.(*ast.SelectorExpr)
+	if !isSel || sel.Sel.Name != "SubResource" || len(call.Args) != 1 {
+		return "status", true
+	}
+	val := info.Types[call.Args[0]].Value
+	if val == nil || val.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(val), true
+}
+
+// watchedType returns the type of object watched by the given argument to the
+// controller builder.  Older versions of controller-runtime take a source, so
+// for a source.Kind{Type: ...} literal, that's the type of its Type field.
+func watchedType(info *types.Info, arg ast.Expr) types.Type {
+	lit := arg
+	if unary, isUnary := lit.(*ast.UnaryExpr); isUnary {
+		lit = unary.X
+	}
+	compLit, isCompLit := lit.(*ast.CompositeLit)
+	if !isCompLit {
+		return info.TypeOf(arg)
+	}
+	named, isNamed := info.TypeOf(compLit).(*types.Named)
+	if !isNamed || named.Obj().Pkg() == nil || loader.NonVendorPath(named.Obj().Pkg().Path()) != sourcePkgPath || named.Obj().Name() != "Kind" {
+		return info.TypeOf(arg)
+	}
+	for _, elt := range compLit.Elts {
+		kv, isKV := elt.(*ast.KeyValueExpr)
+		if !isKV {
+			continue
+		}
+		if key, isIdent := kv.Key.(*ast.Ident); isIdent && key.Name == "Type" {
+			return info.TypeOf(kv.Value)
+		}
+	}
+	return nil
+}
+
+// typeCheckBodies type-checks the given package, including function bodies,
+// which the loader normally skips.
+//
+// The packages used in the bodies are type-checked first (see
+// typeCheckUsedImports), so that the types we find in them are complete.
+func (i *ruleInferrer) typeCheckBodies(root *loader.Package) *types.Info {
+	i.packages[loader.NonVendorPath(root.PkgPath)] = root
+	i.typeCheckUsedImports(root)
+
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	config := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			if imported := root.Imports()[path]; imported != nil && imported.Types != nil && imported.Types.Complete() {
+				return imported.Types, nil
+			}
+			return types.NewPackage(path, ""), nil
+		}),
+		// errors here were already reported when the package was loaded,
+		// and we make do with whatever type information we end up with.
+		Error: func(error) {},
+	}
+	_ = types.NewChecker(config, root.Fset, types.NewPackage(root.PkgPath, root.Name), info).Files(root.Syntax)
+	return info
+}
+
+// typeCheckUsedImports type-checks the imports of the given package that are
+// actually referred to in it (like the client, or the API packages of the
+// objects passed to it), recording them by path.
+//
+// Their own imports are only type-checked as far as their type declarations
+// need, rather than type-checking the whole dependency graph.
+func (i *ruleInferrer) typeCheckUsedImports(pkg *loader.Package) {
+	pkg.NeedSyntax()
+	for _, file := range pkg.Syntax {
+		byName := make(map[string]*loader.Package)
+		for _, importSpec := range file.Imports {
+			path, err := strconv.Unquote(importSpec.Path.Value)
+			if err != nil {
+				continue
+			}
+			imported := pkg.Imports()[path]
+			if imported == nil {
+				continue
+			}
+			switch {
+			case importSpec.Name == nil:
+				byName[imported.Name] = imported
+			case importSpec.Name.Name == ".":
+				// anything could come from a dot import
+				i.typeCheckImport(imported)
+			default:
+				byName[importSpec.Name.Name] = imported
+			}
+		}
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			sel, isSel := node.(*ast.SelectorExpr)
+			if !isSel {
+				return true
+			}
+			if ident, isIdent := sel.X.(*ast.Ident); isIdent && byName[ident.Name] != nil {
+				i.typeCheckImport(byName[ident.Name])
+			}
+			return true
+		})
+	}
+}
+
+// typeCheckImport type-checks the given imported package (if it hasn't been
+// already), recording it by path.
+func (i *ruleInferrer) typeCheckImport(imported *loader.Package) {
+	path := loader.NonVendorPath(imported.PkgPath)
+	if _, seen := i.packages[path]; seen {
+		return
+	}
+	i.packages[path] = imported
+	i.checker.Check(imported)
+}
+
+// addRule adds a rule granting the given verbs on the resource (plus the given
+// subresource suffix) corresponding to the given object type, if it can be resolved,
+// noting the position of the call that needs it.
+func (i *ruleInferrer) addRule(pos token.Position, objType types.Type, subresource string, verbs []string) {
+	group, resource, resolved := i.resourceFor(objType)
+	if !resolved {
+		return
+	}
+	i.uses = append(i.uses, ruleUse{
+		rule: &Rule{
+			Groups:    []string{group},
+			Resources: []string{resource + subresource},
+			Verbs:     verbs,
+		},
+		pos: pos,
+	})
+}
+
+// resourceFor resolves the given object type (or list type) to its group and resource.
+func (i *ruleInferrer) resourceFor(objType types.Type) (group, resource string, resolved bool) {
+	named := namedObject(objType)
+	if named == nil {
+		return "", "", false
+	}
+	// list types resolve to their items' kind
+	if itemsType := listItems(named); itemsType != nil {
+		named = itemsType
+	}
+
+	typePkg := named.Obj().Pkg()
+	if typePkg == nil {
+		return "", "", false
+	}
+	pkg := i.packages[loader.NonVendorPath(typePkg.Path())]
+	if pkg == nil {
+		return "", "", false
+	}
+	kind := named.Obj().Name()
+
+	// Kubernetes API packages declare a GroupName constant, while
+	// kubebuilder-style API packages use the +groupName marker.
+	if groupConst, isConst := typePkg.Scope().Lookup("GroupName").(*types.Const); isConst && groupConst.Val().Kind() == constant.String {
+		group = constant.StringVal(groupConst.Val())
+	} else {
+		pkgMarkers, err := markers.PackageMarkers(i.collector, pkg)
+		if err != nil {
+			pkg.AddError(err)
+			return "", "", false
+		}
+		groupName := pkgMarkers.Get("groupName")
+		if groupName == nil {
+			return "", "", false
+		}
+		group = groupName.(string)
+	}
+
+	resource = flect.Pluralize(strings.ToLower(kind))
+	typeMarkers, err := i.collector.MarkersInPackage(pkg)
+	if err != nil {
+		pkg.AddError(err)
+		return "", "", false
+	}
+	loader.EachType(pkg, func(_ *ast.File, _ *ast.GenDecl, spec *ast.TypeSpec) {
+		if spec.Name.Name != kind {
+			return
+		}
+		if resourceMarker, hasResource := typeMarkers[spec].Get("kubebuilder:resource").(crdmarkers.Resource); hasResource && resourceMarker.Path != "" {
+			resource = resourceMarker.Path
+		}
+	})
+
+	return group, resource, true
+}
+
+// namedObject returns the named struct type of the given (pointer to an) object, if any.
+func namedObject(objType types.Type) *types.Named {
+	if objType == nil {
+		return nil
+	}
+	if ptr, isPtr := objType.(*types.Pointer); isPtr {
+		objType = ptr.Elem()
+	}
+	named, isNamed := objType.(*types.Named)
+	if !isNamed {
+		return nil
+	}
+	if _, isStruct := named.Underlying().(*types.Struct); !isStruct {
+		return nil
+	}
+	return named
+}
+
+// listItems returns the item type of the given list type (a struct with an
+// Items slice field), or nil if it's not a list.
+func listItems(named *types.Named) *types.Named {
+	structType := named.Underlying().(*types.Struct)
+	for idx := 0; idx < structType.NumFields(); idx++ {
+		field := structType.Field(idx)
+		if field.Name() != "Items" {
+			continue
+		}
+		slice, isSlice := field.Type().(*types.Slice)
+		if !isSlice {
+			return nil
+		}
+		return namedObject(slice.Elem())
+	}
+	return nil
+}
+
+// importerFunc is an implementation of the single-method
+// types.Importer interface based on a function value.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
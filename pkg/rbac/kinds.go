@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// kindParser finds the kinds in the root packages, for own kinds and
+// aggregated roles.
+//
+// The root packages are only parsed (and type-checked) the first time they're
+// needed, and then only once, so that errors in them are only reported once.
+type kindParser struct {
+	ctx *genall.GenerationContext
+
+	parser    *crd.Parser
+	kubeKinds map[schema.GroupKind]struct{}
+}
+
+// newKindParser returns a kindParser for the root packages of the given context.
+func newKindParser(ctx *genall.GenerationContext) *kindParser {
+	return &kindParser{ctx: ctx}
+}
+
+// kinds parses the root packages (if they haven't been yet), returning the
+// parser along with the kinds found in them.
+func (k *kindParser) kinds() (*crd.Parser, map[schema.GroupKind]struct{}) {
+	if k.parser == nil {
+		checker := k.ctx.Checker
+		if checker == nil {
+			// the generator doesn't ask for type-checking, since only own kinds
+			// and aggregated roles need it, so only check what the kinds need
+			checker = &loader.TypeChecker{
+				NodeFilters: []loader.NodeFilter{crd.Generator{}.CheckFilter()},
+			}
+		}
+		k.parser = &crd.Parser{
+			Collector: k.ctx.Collector,
+			Checker:   checker,
+		}
+		crd.AddKnownTypes(k.parser)
+		for _, root := range k.ctx.Roots {
+			k.parser.NeedPackage(root)
+		}
+		k.kubeKinds = crd.FindKubeKinds(k.parser, crd.FindMetav1(k.ctx.Roots))
+	}
+	return k.parser, k.kubeKinds
+}
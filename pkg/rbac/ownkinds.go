@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var (
+	// OwnKindsDefinition is a marker for granting access to all the kinds
+	// in the root packages.
+	OwnKindsDefinition = markers.Must(markers.MakeDefinition("kubebuilder:rbac:ownKinds", markers.DescribesPackage, OwnKinds(false)))
+)
+
+// +controllertools:marker:generateHelp:category=RBAC
+
+// OwnKinds grants access to all the kinds in the root packages.
+//
+// That's full access to each kind, update and patch on its status
+// subresource (if it has one), and update on its finalizers (needed to block
+// its deletion from owner references when the OwnerReferencesPermissionEnforcement
+// admission plugin is enabled).
+type OwnKinds bool
+
+// ownKindsVerbs are the verbs granted on each of the operator's own kinds.
+var ownKindsVerbs = []string{"create", "delete", "get", "list", "patch", "update", "watch"}
+
+// ownKind is one of the kinds in the root packages.
+type ownKind struct {
+	group     string
+	plural    string
+	hasStatus bool
+}
+
+// findOwnKinds finds the kinds in the root packages, as found by
+// crd.FindKubeKinds, ordered by group and kind.
+func findOwnKinds(kinds *kindParser) []ownKind {
+	parser, kubeKinds := kinds.kinds()
+
+	var groupKinds []schema.GroupKind
+	for groupKind := range kubeKinds {
+		groupKinds = append(groupKinds, groupKind)
+	}
+	sort.Slice(groupKinds, func(i, j int) bool {
+		if groupKinds[i].Group != groupKinds[j].Group {
+			return groupKinds[i].Group < groupKinds[j].Group
+		}
+		return groupKinds[i].Kind < groupKinds[j].Kind
+	})
+
+	var ownKinds []ownKind
+	for _, groupKind := range groupKinds {
+		parser.NeedCRDFor(groupKind, nil)
+		kindCRD, generated := parser.CustomResourceDefinitions[groupKind]
+		if !generated {
+			continue
+		}
+		kind := ownKind{group: groupKind.Group, plural: kindCRD.Spec.Names.Plural}
+		for _, ver := range kindCRD.Spec.Versions {
+			if ver.Subresources != nil && ver.Subresources.Status != nil {
+				kind.hasStatus = true
+				break
+			}
+		}
+		ownKinds = append(ownKinds, kind)
+	}
+	return ownKinds
+}
+
+// rules returns new rules granting access to this kind, as per OwnKinds.
+func (k ownKind) rules() []*Rule {
+	rules := []*Rule{{
+		Groups:    []string{k.group},
+		Resources: []string{k.plural},
+		Verbs:     append([]string(nil), ownKindsVerbs...),
+	}}
+	if k.hasStatus {
+		rules = append(rules, &Rule{
+			Groups:    []string{k.group},
+			Resources: []string{k.plural + "/status"},
+			Verbs:     []string{"patch", "update"},
+		})
+	}
+	return append(rules, &Rule{
+		Groups:    []string{k.group},
+		Resources: []string{k.plural + "/finalizers"},
+		Verbs:     []string{"update"},
+	})
+}
@@ -24,13 +24,17 @@ package rbac
 
 import (
 	"fmt"
+	"go/token"
 	"sort"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
 	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
@@ -38,6 +42,9 @@ var (
 	// RuleDefinition is a marker for defining RBAC rules.
 	// Call ToRule on the value to get a Kubernetes RBAC policy rule.
 	RuleDefinition = markers.Must(markers.MakeDefinition("kubebuilder:rbac", markers.DescribesPackage, Rule{}))
+	// RuleTypeDefinition is the same marker as RuleDefinition, but on a type
+	// (e.g. the reconciler needing the rules) instead of a package.
+	RuleTypeDefinition = markers.Must(markers.MakeDefinition("kubebuilder:rbac", markers.DescribesType, Rule{}))
 )
 
 // +controllertools:marker:generateHelp:category=RBAC
@@ -61,6 +68,24 @@ type Rule struct {
 	// If not set, the Rule belongs to the generated ClusterRole.
 	// If set, the Rule belongs to a Role, whose namespace is specified by this field.
 	Namespace string `marker:",optional"`
+	// Namespaces specifies several namespaces for the Rule at once.
+	//
+	// The Rule belongs to a Role in each of these namespaces (plus the one
+	// specified by Namespace, if set), as if repeated for each namespace.
+	Namespaces []string `marker:",optional"`
+	// NamespaceFromEnv specifies an environment variable holding the namespace
+	// of the Rule, for namespaces only known at deploy time.
+	//
+	// The Rule belongs to a single Role, whose namespace is a "${VAR}"
+	// placeholder to be filled in when deploying, e.g. with envsubst, or by
+	// replacing it in a kustomize or Helm template.  It can't be combined
+	// with Namespace or Namespaces.
+	NamespaceFromEnv string `marker:",optional"`
+	// RoleName specifies the name of the ClusterRole or Role this Rule belongs to.
+	//
+	// If not set, the Rule belongs to the roles named after the generator's
+	// RoleName.  Roles with other names are written to their own files.
+	RoleName string `marker:",optional"`
 }
 
 // ruleKey represents the resources and non-resources a Rule applies.
@@ -100,7 +125,13 @@ func (r *Rule) addVerbs(verbs []string) {
 }
 
 // normalize removes duplicates from each field of a Rule, and sorts each field.
+// The "core" group is normalized to its actual name ("").
 func (r *Rule) normalize() {
+	for i, group := range r.Groups {
+		if group == "core" {
+			r.Groups[i] = ""
+		}
+	}
 	r.Groups = removeDupAndSort(r.Groups)
 	r.Resources = removeDupAndSort(r.Resources)
 	r.ResourceNames = removeDupAndSort(r.ResourceNames)
@@ -126,6 +157,25 @@ func removeDupAndSort(strs []string) []string {
 	return result
 }
 
+// namespaces returns the namespaces of the Roles this Rule belongs to, or
+// just "" if it belongs to a ClusterRole.
+func (r *Rule) namespaces() ([]string, error) {
+	if r.NamespaceFromEnv != "" {
+		if r.Namespace != "" || len(r.Namespaces) > 0 {
+			return nil, fmt.Errorf("namespaceFromEnv may not be combined with namespace or namespaces")
+		}
+		return []string{"${" + r.NamespaceFromEnv + "}"}, nil
+	}
+	namespaces := r.Namespaces
+	if r.Namespace != "" {
+		namespaces = append([]string{r.Namespace}, namespaces...)
+	}
+	if len(namespaces) == 0 {
+		return []string{""}, nil
+	}
+	return removeDupAndSort(namespaces), nil
+}
+
 // ToRule converts this rule to its Kubernetes API form.
 func (r *Rule) ToRule() rbacv1.PolicyRule {
 	// fix the group names first, since letting people type "core" is nice
@@ -146,9 +196,66 @@ func (r *Rule) ToRule() rbacv1.PolicyRule {
 // +controllertools:marker:generateHelp
 
 // Generator generates ClusterRole objects.
+//
+// If ServiceAccount is set, it also generates the ServiceAccount, plus
+// bindings granting it the generated ClusterRole and Roles named RoleName.
 type Generator struct {
 	// RoleName sets the name of the generated ClusterRole.
+	//
+	// Rules specifying their own roleName are generated into separately named
+	// roles instead, each written to their own file (<roleName>_role.yaml).
 	RoleName string
+
+	// ServiceAccount sets the name of a ServiceAccount to generate and bind the
+	// generated roles to.
+	//
+	// The ClusterRole is bound with a ClusterRoleBinding, and each Role with a
+	// RoleBinding in its namespace, all named after RoleName (with a "-binding"
+	// suffix).  The ServiceAccount and bindings are written to service_account.yaml
+	// and role_binding.yaml, respectively.  The ServiceAccount is generated even
+	// if there are no roles named RoleName to bind it to, in which case there's
+	// no role_binding.yaml.
+	ServiceAccount string `marker:",optional"`
+
+	// ServiceAccountNamespace sets the namespace of the generated ServiceAccount.
+	//
+	// It must be set if ServiceAccount is.
+	ServiceAccountNamespace string `marker:",optional"`
+
+	// Infer adds the rules needed by the controller-runtime client calls and
+	// controller builder watches found in the code, on top of those specified
+	// with RBAC markers.
+	//
+	// Calls whose objects can't be resolved statically (e.g. unstructured
+	// objects) are skipped, and still need markers.
+	Infer bool `marker:",optional"`
+
+	// Audit sets the name of a Markdown report to write, comparing the
+	// permissions granted by RBAC markers with the ones the code needs
+	// (as found by Infer, whether or not it's enabled).
+	//
+	// The report lists permissions that are used but not granted, granted but
+	// unused, and both, each with the positions of the markers granting them
+	// and the calls using them.
+	Audit string `marker:",optional"`
+
+	// Docs sets the name of a Markdown document to write, listing every
+	// generated rule with its API group, resource, subresource, verbs and
+	// scope (cluster or namespace), along with the positions of the RBAC
+	// markers (and inferred calls) it comes from.
+	//
+	// The rules of aggregated roles are included, with the aggregate markers
+	// as their sources.
+	Docs string `marker:",optional"`
+
+	// Strict fails generation for RBAC markers that are likely mistakes, or
+	// grant overly broad access.
+	//
+	// That's markers using wildcards in groups, resources or verbs, using
+	// unknown verbs, mixing urls with groups or resources, or combining
+	// resourceNames with the create or list verbs (which can't be restricted
+	// by name).
+	Strict bool `marker:",optional"`
 }
 
 func (Generator) RegisterMarkers(into *markers.Registry) error {
@@ -156,59 +263,274 @@ func (Generator) RegisterMarkers(into *markers.Registry) error {
 		return err
 	}
 	into.AddHelp(RuleDefinition, Rule{}.Help())
-	return nil
+	if err := into.Register(RuleTypeDefinition); err != nil {
+		return err
+	}
+	into.AddHelp(RuleTypeDefinition, Rule{}.Help())
+	if err := into.Register(OwnKindsDefinition); err != nil {
+		return err
+	}
+	into.AddHelp(OwnKindsDefinition, OwnKinds(false).Help())
+	if err := into.Register(AggregateDefinition); err != nil {
+		return err
+	}
+	into.AddHelp(AggregateDefinition, Aggregate{}.Help())
+	// needed to figure out resource names and subresources for aggregated roles and own kinds
+	return crdmarkers.Register(into)
 }
 
-// GenerateRoles generate a slice of objs representing either a ClusterRole or a Role object
-// The order of the objs in the returned slice is stable and determined by their namespaces.
-func GenerateRoles(ctx *genall.GenerationContext, roleName string) ([]interface{}, error) {
-	rulesByNS := make(map[string][]*Rule)
+// sourcedRule is a Rule merged from several Rules with the same ruleKey,
+// along with the positions of the markers (or calls) they come from.
+type sourcedRule struct {
+	rule    *Rule
+	sources []token.Position
+}
+
+// ruleSet merges Rules with the same ruleKey.
+type ruleSet map[ruleKey]*sourcedRule
+
+// add merges a copy of the given Rule into the set, recording the given
+// source (if it's valid).
+func (s ruleSet) add(rule *Rule, source token.Position) {
+	// copy the rule, since rules get merged in place
+	ruleCopy := *rule
+	key := ruleCopy.key()
+	merged, ok := s[key]
+	if !ok {
+		merged = &sourcedRule{rule: &ruleCopy}
+		s[key] = merged
+	} else {
+		merged.rule.addVerbs(ruleCopy.Verbs)
+	}
+	if source.IsValid() {
+		merged.sources = append(merged.sources, source)
+	}
+}
+
+// sorted returns the merged Rules, sorted according to their ruleKeys.
+func (s ruleSet) sorted() []*sourcedRule {
+	keys := make([]ruleKey, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Sort(ruleKeys(keys))
+
+	rules := make([]*sourcedRule, 0, len(keys))
+	for _, key := range keys {
+		rules = append(rules, s[key])
+	}
+	return rules
+}
+
+// policyRules returns the merged Rules in their Kubernetes API form, sorted
+// according to their ruleKeys.
+func (s ruleSet) policyRules() []rbacv1.PolicyRule {
+	var policyRules []rbacv1.PolicyRule
+	for _, merged := range s.sorted() {
+		policyRules = append(policyRules, merged.rule.ToRule())
+	}
+	return policyRules
+}
+
+// groupedRules holds merged Rules grouped by role name, then namespace.
+type groupedRules map[string]map[string]ruleSet
+
+// groupRules groups the given extra rules (e.g. inferred ones), plus the
+// given rules from RBAC markers, by role name and namespace.  Rules without a
+// RoleName belong to the roles named after the given role name.
+//
+// Errors in rules from markers are reported at the markers' positions, while
+// errors in extra rules are returned.
+func groupRules(roleName string, rules []ruleMarker, extraRules []ruleUse) (groupedRules, error) {
+	grouped := make(groupedRules)
+	addRule := func(rule *Rule, source token.Position) error {
+		namespaces, err := rule.namespaces()
+		if err != nil {
+			return err
+		}
+		name := rule.RoleName
+		if name == "" {
+			name = roleName
+		}
+		if _, ok := grouped[name]; !ok {
+			grouped[name] = make(map[string]ruleSet)
+		}
+		for _, ns := range namespaces {
+			if _, ok := grouped[name][ns]; !ok {
+				grouped[name][ns] = make(ruleSet)
+			}
+			grouped[name][ns].add(rule, source)
+		}
+		return nil
+	}
+
+	for _, use := range extraRules {
+		if err := addRule(use.rule, use.pos); err != nil {
+			return nil, err
+		}
+	}
+	for _, marker := range rules {
+		if err := addRule(marker.rule, marker.position()); err != nil {
+			marker.pkg.AddError(loader.ErrFromPos(err, marker.pos))
+		}
+	}
+	return grouped, nil
+}
+
+// roles generates the ClusterRole and Roles for the grouped rules, by name.
+func (g groupedRules) roles() map[string][]interface{} {
+	rolesByName := make(map[string][]interface{})
+	for name, rulesByNS := range g {
+		if roles := generateRolesFor(name, rulesByNS); len(roles) > 0 {
+			rolesByName[name] = roles
+		}
+	}
+	return rolesByName
+}
+
+// otherRoleNames returns the given names other than the given role name,
+// sorted, which is the order their roles come in after the ones named after
+// the given role name.
+func otherRoleNames(roleName string, names []string) []string {
+	var others []string
+	for _, name := range names {
+		if name != roleName {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return others
+}
+
+// ruleMarker is a Rule from an RBAC marker, along with the marker's position
+// and the package it's in.
+type ruleMarker struct {
+	rule *Rule
+	pos  token.Pos
+	pkg  *loader.Package
+}
+
+// position returns the position of the marker in its package's file set.
+func (m ruleMarker) position() token.Position {
+	return m.pkg.Fset.Position(m.pos)
+}
+
+// rbacMarkers are the markers collected from the root packages.
+type rbacMarkers struct {
+	// rules are the Rules from the RBAC markers, plus the ones for own kinds.
+	rules []ruleMarker
+	// aggregates are the Aggregate markers.
+	aggregates []aggregateMarker
+}
+
+// collectMarkers collects the markers in the root packages, using the given
+// kindParser to find the kinds they refer to.
+//
+// Rules come from RBAC markers both at the package level and on types.  Rules
+// for the kinds in the root packages are included for each OwnKinds marker,
+// at its position.
+func collectMarkers(ctx *genall.GenerationContext, kinds *kindParser) rbacMarkers {
+	var collected rbacMarkers
+	var ownKindsMarkers []ruleMarker
 	for _, root := range ctx.Roots {
-		markerSet, err := markers.PackageMarkers(ctx.Collector, root)
+		markerSet, err := ctx.Collector.MarkersInPackage(root)
 		if err != nil {
 			root.AddError(err)
+			continue
 		}
-
-		// group RBAC markers by namespace
-		for _, markerValue := range markerSet[RuleDefinition.Name] {
-			rule := markerValue.(Rule)
-			namespace := rule.Namespace
-			if _, ok := rulesByNS[namespace]; !ok {
-				rules := make([]*Rule, 0)
-				rulesByNS[namespace] = rules
+		positions, err := ctx.Collector.MarkerPositionsInPackage(root)
+		if err != nil {
+			root.AddError(err)
+			continue
+		}
+		for _, file := range root.Syntax {
+			for i, markerValue := range markerSet[file][RuleDefinition.Name] {
+				rule := markerValue.(Rule)
+				collected.rules = append(collected.rules, ruleMarker{
+					rule: &rule,
+					pos:  positions[file].Get(RuleDefinition.Name, i),
+					pkg:  root,
+				})
+			}
+			for i, markerValue := range markerSet[file][OwnKindsDefinition.Name] {
+				if markerValue.(OwnKinds) {
+					ownKindsMarkers = append(ownKindsMarkers, ruleMarker{
+						pos: positions[file].Get(OwnKindsDefinition.Name, i),
+						pkg: root,
+					})
+				}
+			}
+			for i, markerValue := range markerSet[file][AggregateDefinition.Name] {
+				collected.aggregates = append(collected.aggregates, aggregateMarker{
+					aggregate: markerValue.(Aggregate),
+					pos:       positions[file].Get(AggregateDefinition.Name, i),
+					pkg:       root,
+				})
 			}
-			rulesByNS[namespace] = append(rulesByNS[namespace], &rule)
 		}
-	}
 
-	// NormalizeRules merge Rule with the same ruleKey and sort the Rules
-	NormalizeRules := func(rules []*Rule) []rbacv1.PolicyRule {
-		ruleMap := make(map[ruleKey]*Rule)
-		// all the Rules having the same ruleKey will be merged into the first Rule
-		for _, rule := range rules {
-			key := rule.key()
-			if _, ok := ruleMap[key]; !ok {
-				ruleMap[key] = rule
-				continue
+		if err := markers.EachType(ctx.Collector, root, func(info *markers.TypeInfo) {
+			for i, markerValue := range info.Markers[RuleTypeDefinition.Name] {
+				rule := markerValue.(Rule)
+				collected.rules = append(collected.rules, ruleMarker{
+					rule: &rule,
+					pos:  info.MarkerPositions.Get(RuleTypeDefinition.Name, i),
+					pkg:  root,
+				})
 			}
-			ruleMap[key].addVerbs(rule.Verbs)
+		}); err != nil {
+			root.AddError(err)
 		}
+	}
 
-		// sort the Rules in rules according to their ruleKeys
-		keys := make([]ruleKey, 0, len(ruleMap))
-		for key := range ruleMap {
-			keys = append(keys, key)
+	if len(ownKindsMarkers) > 0 {
+		ownKinds := findOwnKinds(kinds)
+		for _, marker := range ownKindsMarkers {
+			// each marker gets its own rules, since rules get normalized in place
+			for _, kind := range ownKinds {
+				for _, rule := range kind.rules() {
+					marker.rule = rule
+					collected.rules = append(collected.rules, marker)
+				}
+			}
 		}
-		sort.Sort(ruleKeys(keys))
+	}
+	return collected
+}
 
-		var policyRules []rbacv1.PolicyRule
-		for _, key := range keys {
-			policyRules = append(policyRules, ruleMap[key].ToRule())
+// GenerateRoles generate a slice of objs representing either a ClusterRole or a Role object
+// The order of the objs in the returned slice is stable and determined by their namespaces.
+//
+// Any extra rules (e.g. those returned by InferRules) are merged with the ones
+// from the RBAC markers.  Only the roles named after the given role name are
+// returned; use GenerateNamedRoles for the ones for rules with their own RoleName.
+func GenerateRoles(ctx *genall.GenerationContext, roleName string, extraRules ...*Rule) ([]interface{}, error) {
+	rolesByName, err := GenerateNamedRoles(ctx, roleName, extraRules...)
+	if err != nil {
+		return nil, err
+	}
+	return rolesByName[roleName], nil
+}
 
-		}
-		return policyRules
+// GenerateNamedRoles functions like GenerateRoles, except that it returns the
+// generated ClusterRole and Roles grouped by name.  Rules without a RoleName
+// belong to the roles named after the given role name.
+func GenerateNamedRoles(ctx *genall.GenerationContext, roleName string, extraRules ...*Rule) (map[string][]interface{}, error) {
+	uses := make([]ruleUse, len(extraRules))
+	for i, rule := range extraRules {
+		uses[i] = ruleUse{rule: rule}
+	}
+	grouped, err := groupRules(roleName, collectMarkers(ctx, newKindParser(ctx)).rules, uses)
+	if err != nil {
+		return nil, err
 	}
+	return grouped.roles(), nil
+}
 
+// generateRolesFor generates the ClusterRole and Roles with the given name
+// from the given rules, grouped by namespace.
+// The order of the objs in the returned slice is stable and determined by their namespaces.
+func generateRolesFor(roleName string, rulesByNS map[string]ruleSet) []interface{} {
 	// collect all the namespaces and sort them
 	var namespaces []string
 	for ns := range rulesByNS {
@@ -219,8 +541,7 @@ func GenerateRoles(ctx *genall.GenerationContext, roleName string) ([]interface{
 	// process the items in rulesByNS by the order specified in `namespaces` to make sure that the Role order is stable
 	var objs []interface{}
 	for _, ns := range namespaces {
-		rules := rulesByNS[ns]
-		policyRules := NormalizeRules(rules)
+		policyRules := rulesByNS[ns].policyRules()
 		if len(policyRules) == 0 {
 			continue
 		}
@@ -250,18 +571,144 @@ func GenerateRoles(ctx *genall.GenerationContext, roleName string) ([]interface{
 		}
 	}
 
-	return objs, nil
+	return objs
+}
+
+// GenerateBindings generates a ServiceAccount with the given name and namespace,
+// plus a slice of objs binding it to each of the given ClusterRoles and Roles (as
+// returned by GenerateRoles): a ClusterRoleBinding for each ClusterRole, and a
+// RoleBinding in the Role's namespace for each Role.
+func GenerateBindings(roles []interface{}, serviceAccount, namespace string) (corev1.ServiceAccount, []interface{}) {
+	account := corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ServiceAccount",
+			APIVersion: corev1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceAccount,
+			Namespace: namespace,
+		},
+	}
+	subjects := []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      serviceAccount,
+		Namespace: namespace,
+	}}
+
+	var bindings []interface{}
+	for _, role := range roles {
+		switch role := role.(type) {
+		case rbacv1.ClusterRole:
+			bindings = append(bindings, rbacv1.ClusterRoleBinding{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "ClusterRoleBinding",
+					APIVersion: rbacv1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: role.Name + "-binding",
+				},
+				RoleRef: rbacv1.RoleRef{
+					APIGroup: rbacv1.GroupName,
+					Kind:     "ClusterRole",
+					Name:     role.Name,
+				},
+				Subjects: subjects,
+			})
+		case rbacv1.Role:
+			bindings = append(bindings, rbacv1.RoleBinding{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "RoleBinding",
+					APIVersion: rbacv1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      role.Name + "-binding",
+					Namespace: role.Namespace,
+				},
+				RoleRef: rbacv1.RoleRef{
+					APIGroup: rbacv1.GroupName,
+					Kind:     "Role",
+					Name:     role.Name,
+				},
+				Subjects: subjects,
+			})
+		}
+	}
+	return account, bindings
 }
 
 func (g Generator) Generate(ctx *genall.GenerationContext) error {
-	objs, err := GenerateRoles(ctx, g.RoleName)
+	if g.ServiceAccount != "" && g.ServiceAccountNamespace == "" {
+		return fmt.Errorf("serviceAccountNamespace must be set when generating a ServiceAccount")
+	}
+
+	// the markers (and kinds) are only collected once, so that errors in them
+	// are only reported once
+	kinds := newKindParser(ctx)
+	collected := collectMarkers(ctx, kinds)
+
+	if g.Strict {
+		checkStrict(collected.rules)
+	}
+
+	// the audit needs the inferred rules whether or not they're generated
+	var inferred []ruleUse
+	if g.Infer || g.Audit != "" {
+		inferred = inferUses(ctx)
+	}
+
+	var extraRules []ruleUse
+	if g.Infer {
+		extraRules = inferred
+	}
+	grouped, err := groupRules(g.RoleName, collected.rules, extraRules)
 	if err != nil {
 		return err
 	}
+	rolesByName := grouped.roles()
+	roles := rolesByName[g.RoleName]
+	aggregatedByRole := aggregatedRules(kinds, collected.aggregates)
+	aggregated := aggregatedRolesFor(g.RoleName, aggregatedByRole)
+
+	if g.Audit != "" {
+		if err := writeAudit(ctx, g.Audit, auditUses(collected.rules, inferred)); err != nil {
+			return err
+		}
+	}
 
-	if len(objs) == 0 {
-		return nil
+	if g.Docs != "" {
+		if err := writeDocs(ctx, g.Docs, documentPermissions(g.RoleName, grouped, aggregatedByRole)); err != nil {
+			return err
+		}
+	}
+
+	if objs := append(roles, aggregated...); len(objs) > 0 {
+		if err := ctx.WriteYAML("role.yaml", objs...); err != nil {
+			return err
+		}
+	}
+
+	var names []string
+	for name := range rolesByName {
+		names = append(names, name)
+	}
+	for _, name := range otherRoleNames(g.RoleName, names) {
+		if err := ctx.WriteYAML(name+"_role.yaml", rolesByName[name]...); err != nil {
+			return err
+		}
 	}
 
-	return ctx.WriteYAML("role.yaml", objs...)
+	if g.ServiceAccount == "" {
+		return nil
+	}
+	// aggregated roles are bound through the user-facing roles, and separately
+	// named roles are meant for other components, so neither are bound to the
+	// ServiceAccount
+	account, bindings := GenerateBindings(roles, g.ServiceAccount, g.ServiceAccountNamespace)
+	if err := ctx.WriteYAML("service_account.yaml", account); err != nil {
+		return err
+	}
+	if len(bindings) == 0 {
+		return nil
+	}
+	return ctx.WriteYAML("role_binding.yaml", bindings...)
 }
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"sigs.k8s.io/controller-tools/pkg/genall"
 	"sigs.k8s.io/controller-tools/pkg/loader"
@@ -34,9 +36,10 @@ var _ = Describe("ClusterRole generated by the RBAC Generator", func() {
 			pkgs, err := loader.LoadRoots(".")
 			Expect(err).NotTo(HaveOccurred())
 
-			By("registering RBAC rule marker")
+			By("registering RBAC rule markers")
 			reg := &markers.Registry{}
 			Expect(reg.Register(rbac.RuleDefinition)).To(Succeed())
+			Expect(reg.Register(rbac.RuleTypeDefinition)).To(Succeed())
 
 			By("creating GenerationContext")
 			ctx := &genall.GenerationContext{
@@ -53,7 +56,9 @@ var _ = Describe("ClusterRole generated by the RBAC Generator", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			By("parsing the desired YAML")
-			for i, expectedRoleBytes := range bytes.Split(expectedFile, []byte("\n---\n"))[1:] {
+			expectedRolesBytes := bytes.Split(expectedFile, []byte("\n---\n"))[1:]
+			Expect(objs).To(HaveLen(len(expectedRolesBytes)), "only the roles named manager-role should be generated")
+			for i, expectedRoleBytes := range expectedRolesBytes {
 				By(fmt.Sprintf("comparing the generated Role and expected Role (Pair %d)", i))
 				obj := objs[i]
 				switch obj := obj.(type) {
@@ -70,4 +75,551 @@ var _ = Describe("ClusterRole generated by the RBAC Generator", func() {
 
 		})
 	}
+
+	It("should generate separately named roles for rules with their own role name", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering RBAC rule markers")
+		reg := &markers.Registry{}
+		Expect(reg.Register(rbac.RuleDefinition)).To(Succeed())
+		Expect(reg.Register(rbac.RuleTypeDefinition)).To(Succeed())
+
+		By("generating the roles")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Roots:     pkgs,
+		}
+		rolesByName, err := rbac.GenerateNamedRoles(ctx, "manager-role")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rolesByName).To(HaveLen(2))
+		Expect(rolesByName).To(HaveKey("manager-role"))
+
+		By("loading the desired YAML")
+		expectedFile, err := ioutil.ReadFile("webhook-role_role.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("comparing the generated roles with the expected ones")
+		expectedRoleBytes := bytes.Split(expectedFile, []byte("\n---\n"))[1:]
+		Expect(rolesByName["webhook-role"]).To(HaveLen(len(expectedRoleBytes)))
+		var expectedClusterRole rbacv1.ClusterRole
+		Expect(yaml.Unmarshal(expectedRoleBytes[0], &expectedClusterRole)).To(Succeed())
+		Expect(rolesByName["webhook-role"][0]).To(Equal(expectedClusterRole), "type not as expected, check pkg/rbac/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(rolesByName["webhook-role"][0], expectedClusterRole))
+		var expectedRole rbacv1.Role
+		Expect(yaml.Unmarshal(expectedRoleBytes[1], &expectedRole)).To(Succeed())
+		Expect(rolesByName["webhook-role"][1]).To(Equal(expectedRole), "type not as expected, check pkg/rbac/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(rolesByName["webhook-role"][1], expectedRole))
+	})
+
+	It("should bind the generated roles to the ServiceAccount", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering RBAC rule markers")
+		reg := &markers.Registry{}
+		Expect(reg.Register(rbac.RuleDefinition)).To(Succeed())
+		Expect(reg.Register(rbac.RuleTypeDefinition)).To(Succeed())
+
+		By("generating the roles and bindings")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Roots:     pkgs,
+		}
+		rolesByName, err := rbac.GenerateNamedRoles(ctx, "manager-role")
+		Expect(err).NotTo(HaveOccurred())
+		account, bindings := rbac.GenerateBindings(rolesByName["manager-role"], "manager", "system")
+
+		By("checking the ServiceAccount")
+		Expect(account.Kind).To(Equal("ServiceAccount"))
+		Expect(account.Name).To(Equal("manager"))
+		Expect(account.Namespace).To(Equal("system"))
+
+		By("checking that there's a binding for each role")
+		subjects := []rbacv1.Subject{{Kind: "ServiceAccount", Name: "manager", Namespace: "system"}}
+		Expect(bindings).To(Equal([]interface{}{
+			rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{Kind: "ClusterRoleBinding", APIVersion: "rbac.authorization.k8s.io/v1"},
+				ObjectMeta: metav1.ObjectMeta{Name: "manager-role-binding"},
+				RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "manager-role"},
+				Subjects:   subjects,
+			},
+			rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{Kind: "RoleBinding", APIVersion: "rbac.authorization.k8s.io/v1"},
+				ObjectMeta: metav1.ObjectMeta{Name: "manager-role-binding", Namespace: "park"},
+				RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "manager-role"},
+				Subjects:   subjects,
+			},
+			rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{Kind: "RoleBinding", APIVersion: "rbac.authorization.k8s.io/v1"},
+				ObjectMeta: metav1.ObjectMeta{Name: "manager-role-binding", Namespace: "zoo"},
+				RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: "manager-role"},
+				Subjects:   subjects,
+			},
+		}))
+	})
+
+	It("should generate the ServiceAccount even without roles to bind it to", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots("./otherroles")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering RBAC rule markers")
+		reg := &markers.Registry{}
+		Expect(reg.Register(rbac.RuleDefinition)).To(Succeed())
+		Expect(reg.Register(rbac.RuleTypeDefinition)).To(Succeed())
+
+		By("generating the roles and ServiceAccount")
+		outputDir, err := ioutil.TempDir("", "rbac-service-account")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outputDir)
+		ctx := &genall.GenerationContext{
+			Collector:  &markers.Collector{Registry: reg},
+			Roots:      pkgs,
+			OutputRule: genall.OutputToDirectory(outputDir),
+		}
+		gen := rbac.Generator{RoleName: "manager-role", ServiceAccount: "manager", ServiceAccountNamespace: "system"}
+		Expect(gen.Generate(ctx)).To(Succeed())
+
+		By("checking that the ServiceAccount is written, but no bindings")
+		Expect(filepath.Join(outputDir, "webhook-role_role.yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(outputDir, "service_account.yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(outputDir, "role_binding.yaml")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(outputDir, "role.yaml")).NotTo(BeAnExistingFile())
+	})
+
+	It("should grant access to the kinds in the roots with the ownKinds marker", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots("./ownkinds")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering the RBAC markers")
+		reg := &markers.Registry{}
+		Expect(rbac.Generator{}.RegisterMarkers(reg)).To(Succeed())
+
+		By("checking that the generator doesn't ask for type-checking")
+		_, needsTypeChecking := interface{}(rbac.Generator{}).(genall.NeedsTypeChecking)
+		Expect(needsTypeChecking).To(BeFalse())
+
+		By("generating the roles without a type-checker, like the generator is run")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Roots:     pkgs,
+		}
+		objs, err := rbac.GenerateRoles(ctx, "manager-role")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkgs[0].Errors).To(BeEmpty())
+
+		By("checking the rules for each kind")
+		rule := func(resource string, verbs ...string) rbacv1.PolicyRule {
+			return rbacv1.PolicyRule{APIGroups: []string{"things.example.com"}, Resources: []string{resource}, Verbs: verbs}
+		}
+		allVerbs := []string{"create", "delete", "get", "list", "patch", "update", "watch"}
+		Expect(objs).To(Equal([]interface{}{
+			rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{Kind: "ClusterRole", APIVersion: "rbac.authorization.k8s.io/v1"},
+				ObjectMeta: metav1.ObjectMeta{Name: "manager-role"},
+				Rules: []rbacv1.PolicyRule{
+					rule("gizmos", allVerbs...),
+					rule("gizmos/finalizers", "update"),
+					rule("widgets", allVerbs...),
+					rule("widgets/finalizers", "update"),
+					rule("widgets/status", "patch", "update"),
+				},
+			},
+		}))
+
+		By("checking that the rules are traced back to each marker")
+		for _, entry := range rbac.Permissions(ctx, "manager-role", false).Entries {
+			Expect(entry.Sources).To(HaveLen(2))
+			Expect(filepath.Base(entry.Sources[0].String())).To(Equal("doc.go:3:1"))
+			Expect(filepath.Base(entry.Sources[1].String())).To(Equal("types.go:2:1"))
+		}
+	})
+
+	It("should only report errors in the kinds once", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots("./kinderrors")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering the RBAC markers")
+		reg := &markers.Registry{}
+		Expect(rbac.Generator{}.RegisterMarkers(reg)).To(Succeed())
+
+		By("generating everything that needs to know about the kinds")
+		ctx := &genall.GenerationContext{
+			Collector:  &markers.Collector{Registry: reg},
+			Checker:    &loader.TypeChecker{},
+			Roots:      pkgs,
+			OutputRule: genall.OutputToNothing,
+		}
+		gen := rbac.Generator{RoleName: "manager-role", Strict: true, Audit: "audit.md", Docs: "docs.md"}
+		Expect(gen.Generate(ctx)).To(Succeed())
+
+		By("checking that the error is only reported once")
+		var errs []string
+		for _, pkgErr := range pkgs[0].Errors {
+			errs = append(errs, filepath.Base(pkgErr.Pos)+": "+pkgErr.Msg)
+		}
+		Expect(errs).To(Equal([]string{"types.go:19:11: map keys must be strings, not int"}))
+	})
+
+	It("should generate ClusterRoles aggregated into the user-facing roles", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots("./aggregate")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering the RBAC markers")
+		reg := &markers.Registry{}
+		Expect(rbac.Generator{}.RegisterMarkers(reg)).To(Succeed())
+
+		By("generating the aggregated roles")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Checker:   &loader.TypeChecker{},
+			Roots:     pkgs,
+		}
+		objs, err := rbac.GenerateAggregatedRoles(ctx, "manager-role")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkgs[0].Errors).To(BeEmpty())
+
+		allVerbs := []string{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"}
+		aggregatedRole := func(role string, rules ...rbacv1.PolicyRule) rbacv1.ClusterRole {
+			return rbacv1.ClusterRole{
+				TypeMeta: metav1.TypeMeta{Kind: "ClusterRole", APIVersion: "rbac.authorization.k8s.io/v1"},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "manager-role-aggregate-to-" + role,
+					Labels: map[string]string{"rbac.authorization.k8s.io/aggregate-to-" + role: "true"},
+				},
+				Rules: rules,
+			}
+		}
+		Expect(objs).To(Equal([]interface{}{
+			aggregatedRole("admin",
+				rbacv1.PolicyRule{APIGroups: []string{"things.example.com"}, Resources: []string{"gizmos"}, Verbs: allVerbs},
+			),
+			aggregatedRole("edit",
+				rbacv1.PolicyRule{APIGroups: []string{"things.example.com"}, Resources: []string{"gizmos", "widgets"}, Verbs: allVerbs},
+				rbacv1.PolicyRule{APIGroups: []string{"things.example.com"}, Resources: []string{"widgets/scale", "widgets/status"}, Verbs: []string{"get", "patch", "update"}},
+			),
+			aggregatedRole("view",
+				rbacv1.PolicyRule{APIGroups: []string{"things.example.com"}, Resources: []string{"gizmos", "widgets"}, Verbs: []string{"get", "list", "watch"}},
+				rbacv1.PolicyRule{APIGroups: []string{"things.example.com"}, Resources: []string{"widgets/scale", "widgets/status"}, Verbs: []string{"get"}},
+			),
+		}))
+
+		By("documenting the aggregated roles, with the aggregate markers as their sources")
+		var documented []string
+		for _, entry := range rbac.Permissions(ctx, "manager-role", false).Entries {
+			var sources []string
+			for _, source := range entry.Sources {
+				sources = append(sources, fmt.Sprintf("%s:%d", filepath.Base(source.Filename), source.Line))
+			}
+			documented = append(documented, fmt.Sprintf("%s %s/%s %v %v", entry.RoleName, entry.Resource, entry.Subresource, entry.Verbs, sources))
+		}
+		Expect(documented).To(Equal([]string{
+			"manager-role-aggregate-to-admin gizmos/ [create delete deletecollection get list patch update watch] [types.go:3]",
+			"manager-role-aggregate-to-edit gizmos/ [create delete deletecollection get list patch update watch] [types.go:2]",
+			"manager-role-aggregate-to-edit widgets/ [create delete deletecollection get list patch update watch] [types.go:2]",
+			"manager-role-aggregate-to-edit widgets/scale [get patch update] [types.go:2]",
+			"manager-role-aggregate-to-edit widgets/status [get patch update] [types.go:2]",
+			"manager-role-aggregate-to-view gizmos/ [get list watch] [types.go:2]",
+			"manager-role-aggregate-to-view widgets/ [get list watch] [types.go:2]",
+			"manager-role-aggregate-to-view widgets/scale [get] [types.go:2]",
+			"manager-role-aggregate-to-view widgets/status [get] [types.go:2]",
+		}))
+	})
+
+	It("should infer rules from controller-runtime client calls", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata/infer")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering the RBAC markers")
+		reg := &markers.Registry{}
+		Expect(rbac.Generator{}.RegisterMarkers(reg)).To(Succeed())
+
+		By("inferring rules and generating the roles")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Checker:   &loader.TypeChecker{},
+			Roots:     pkgs,
+		}
+		objs, err := rbac.GenerateRoles(ctx, "manager-role", rbac.InferRules(ctx)...)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("loading the desired YAML")
+		expectedFile, err := ioutil.ReadFile("role.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("comparing the generated ClusterRole with the expected one")
+		var expectedClusterRole rbacv1.ClusterRole
+		Expect(yaml.Unmarshal(expectedFile, &expectedClusterRole)).To(Succeed())
+		Expect(objs).To(HaveLen(1))
+		Expect(objs[0]).To(Equal(expectedClusterRole), "type not as expected, check pkg/rbac/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(objs[0], expectedClusterRole))
+	})
+
+	It("should infer rules on subresources from the receiver of client calls", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata/infer")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots("./subresources")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering the RBAC markers")
+		reg := &markers.Registry{}
+		Expect(rbac.Generator{}.RegisterMarkers(reg)).To(Succeed())
+
+		By("inferring rules and generating the roles")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Checker:   &loader.TypeChecker{},
+			Roots:     pkgs,
+		}
+		objs, err := rbac.GenerateRoles(ctx, "manager-role", rbac.InferRules(ctx)...)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("loading the desired YAML")
+		expectedFile, err := ioutil.ReadFile("subresources/role.yaml")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("comparing the generated ClusterRole with the expected one")
+		var expectedClusterRole rbacv1.ClusterRole
+		Expect(yaml.Unmarshal(expectedFile, &expectedClusterRole)).To(Succeed())
+		Expect(objs).To(HaveLen(1))
+		Expect(objs[0]).To(Equal(expectedClusterRole), "type not as expected, check pkg/rbac/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(objs[0], expectedClusterRole))
+	})
+
+	It("should document the generated rules and where they come from", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering RBAC rule markers")
+		reg := &markers.Registry{}
+		Expect(reg.Register(rbac.RuleDefinition)).To(Succeed())
+		Expect(reg.Register(rbac.RuleTypeDefinition)).To(Succeed())
+
+		By("documenting the permissions")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Roots:     pkgs,
+		}
+		var actual bytes.Buffer
+		Expect(rbac.Permissions(ctx, "manager-role", false).WriteMarkdown(&actual)).To(Succeed())
+
+		By("comparing it with the desired document")
+		expected, err := ioutil.ReadFile("docs.md")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(actual.String()).To(Equal(string(expected)), "docs not as expected, check pkg/rbac/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(actual.String(), string(expected)))
+	})
+
+	It("should audit granted permissions against the ones used by the code", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata/infer")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots(".")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering the RBAC markers")
+		reg := &markers.Registry{}
+		Expect(rbac.Generator{}.RegisterMarkers(reg)).To(Succeed())
+
+		By("auditing the permissions")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Checker:   &loader.TypeChecker{},
+			Roots:     pkgs,
+		}
+		report := rbac.Audit(ctx)
+
+		type permission struct{ group, resource, verb string }
+		summarize := func(entries []rbac.AuditEntry) map[permission][]string {
+			res := make(map[permission][]string)
+			for _, entry := range entries {
+				var lines []string
+				for _, pos := range entry.GrantedAt {
+					lines = append(lines, fmt.Sprintf("granted@%s:%d", filepath.Base(pos.Filename), pos.Line))
+				}
+				for _, pos := range entry.UsedAt {
+					lines = append(lines, fmt.Sprintf("used@%s:%d", filepath.Base(pos.Filename), pos.Line))
+				}
+				res[permission{entry.Group, entry.Resource, entry.Verb}] = lines
+			}
+			return res
+		}
+
+		By("checking the permissions used but not granted")
+		ungranted := summarize(report.Ungranted)
+		Expect(ungranted).To(HaveLen(14))
+		Expect(ungranted).To(HaveKeyWithValue(permission{"", "pods", "list"}, []string{"used@controller.go:30"}))
+		Expect(ungranted).To(HaveKeyWithValue(permission{"things.example.com", "widgets", "watch"}, []string{"used@controller.go:25", "used@controller.go:58"}))
+		Expect(ungranted).To(HaveKeyWithValue(permission{"things.example.com", "widgets/status", "update"}, []string{"used@controller.go:53"}))
+
+		By("checking the permissions granted but not used")
+		Expect(summarize(report.Unused)).To(Equal(map[permission][]string{
+			{"", "configmaps", "delete"}:                {"granted@controller.go:17"},
+			{"batch", "jobs", "get"}:                    {"granted@controller.go:16"},
+			{"things.example.com", "widgets", "delete"}: {"granted@controller.go:15"},
+		}))
+
+		By("checking the permissions both granted and used")
+		Expect(summarize(report.Used)).To(Equal(map[permission][]string{
+			{"", "configmaps", "create"}: {"granted@controller.go:17", "used@controller.go:34"},
+		}))
+
+		By("writing the report")
+		var out bytes.Buffer
+		Expect(report.WriteMarkdown(&out)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("| core | configmaps | create | controller.go:17:1 | controller.go:34:14 |\n"))
+	})
+
+	It("should generate a Role in each of a rule's namespaces", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots("./namespaces")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering RBAC rule markers")
+		reg := &markers.Registry{}
+		Expect(reg.Register(rbac.RuleDefinition)).To(Succeed())
+		Expect(reg.Register(rbac.RuleTypeDefinition)).To(Succeed())
+
+		By("generating the roles")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Roots:     pkgs,
+		}
+		objs, err := rbac.GenerateRoles(ctx, "manager-role")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("checking that namespaceFromEnv can't be combined with namespace")
+		Expect(pkgs[0].Errors).To(HaveLen(1))
+		Expect(filepath.Base(pkgs[0].Errors[0].Pos) + ": " + pkgs[0].Errors[0].Msg).To(Equal(
+			"controller.go:7:1: namespaceFromEnv may not be combined with namespace or namespaces"))
+
+		By("checking that there's a Role for each namespace")
+		role := func(namespace string, rules ...rbacv1.PolicyRule) rbacv1.Role {
+			return rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{Kind: "Role", APIVersion: "rbac.authorization.k8s.io/v1"},
+				ObjectMeta: metav1.ObjectMeta{Name: "manager-role", Namespace: namespace},
+				Rules:      rules,
+			}
+		}
+		Expect(objs).To(Equal([]interface{}{
+			role("${WATCH_NAMESPACE}",
+				rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list"}},
+			),
+			role("team-a",
+				rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+				rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list", "update", "watch"}},
+			),
+			role("team-b",
+				rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list", "watch"}},
+			),
+			role("team-c",
+				rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+			),
+		}))
+	})
+
+	It("should reject questionable markers at their positions in strict mode", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots("./strict")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("registering RBAC rule markers")
+		reg := &markers.Registry{}
+		Expect(reg.Register(rbac.RuleDefinition)).To(Succeed())
+		Expect(reg.Register(rbac.RuleTypeDefinition)).To(Succeed())
+
+		By("checking the markers")
+		ctx := &genall.GenerationContext{
+			Collector: &markers.Collector{Registry: reg},
+			Roots:     pkgs,
+		}
+		rbac.CheckStrict(ctx)
+
+		By("checking the errors and their positions")
+		var errs []string
+		for _, pkgErr := range pkgs[0].Errors {
+			errs = append(errs, filepath.Base(pkgErr.Pos)+": "+pkgErr.Msg)
+		}
+		Expect(errs).To(ConsistOf(
+			"controller.go:4:1: wildcard groups are not allowed in strict mode",
+			"controller.go:5:1: wildcard verbs are not allowed in strict mode",
+			"controller.go:6:1: unknown verb \"frobnicate\"",
+			"controller.go:7:1: urls may not be mixed with groups or resources in the same rule",
+			"controller.go:8:1: resourceNames have no effect on the \"create\" verb, since it can't be restricted by name",
+			"controller.go:8:1: resourceNames have no effect on the \"list\" verb, since it can't be restricted by name",
+			"controller.go:13:1: wildcard resources are not allowed in strict mode",
+		))
+	})
 })
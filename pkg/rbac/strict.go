@@ -0,0 +1,101 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// knownVerbs are the verbs understood by the Kubernetes API server's
+// authorizers, including the special verbs checked by some admission
+// plugins and APIs (e.g. bind & escalate for RBAC itself).
+var knownVerbs = map[string]bool{
+	"get":              true,
+	"list":             true,
+	"watch":            true,
+	"create":           true,
+	"update":           true,
+	"patch":            true,
+	"delete":           true,
+	"deletecollection": true,
+	"impersonate":      true,
+	"bind":             true,
+	"escalate":         true,
+	"use":              true,
+	"approve":          true,
+	"sign":             true,
+}
+
+// CheckStrict checks the rules from all RBAC markers in the root packages
+// against the stricter rules described by StrictErrors, adding an error at
+// the position of each offending marker to its package.
+func CheckStrict(ctx *genall.GenerationContext) {
+	checkStrict(collectMarkers(ctx, newKindParser(ctx)).rules)
+}
+
+// checkStrict checks the given rules from RBAC markers, as per CheckStrict.
+func checkStrict(rules []ruleMarker) {
+	for _, marker := range rules {
+		for _, err := range StrictErrors(marker.rule) {
+			marker.pkg.AddError(loader.ErrFromPos(err, marker.pos))
+		}
+	}
+}
+
+// StrictErrors returns the problems with the given rule that ToRule lets
+// through, but that likely indicate a mistake or overly broad access: wildcards
+// in groups, resources or verbs, verbs the API server doesn't know about,
+// non-resource URLs mixed with groups or resources, and resource names together
+// with the create or list verbs (which can't be restricted by name, so the
+// rule silently doesn't apply to them).
+func StrictErrors(rule *Rule) []error {
+	var errs []error
+	checkWildcards := func(field string, values []string) {
+		for _, value := range values {
+			if value == "*" {
+				errs = append(errs, fmt.Errorf("wildcard %s are not allowed in strict mode", field))
+				return
+			}
+		}
+	}
+	checkWildcards("groups", rule.Groups)
+	checkWildcards("resources", rule.Resources)
+	checkWildcards("verbs", rule.Verbs)
+
+	for _, verb := range rule.Verbs {
+		if verb != "*" && !knownVerbs[verb] {
+			errs = append(errs, fmt.Errorf("unknown verb %q", verb))
+		}
+	}
+
+	if len(rule.URLs) > 0 && (len(rule.Groups) > 0 || len(rule.Resources) > 0) {
+		errs = append(errs, fmt.Errorf("urls may not be mixed with groups or resources in the same rule"))
+	}
+
+	if len(rule.ResourceNames) > 0 {
+		for _, verb := range rule.Verbs {
+			if verb == "create" || verb == "list" {
+				errs = append(errs, fmt.Errorf("resourceNames have no effect on the %q verb, since it can't be restricted by name", verb))
+			}
+		}
+	}
+
+	return errs
+}
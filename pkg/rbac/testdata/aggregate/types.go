@@ -0,0 +1,40 @@
+// +groupName=things.example.com
+// +kubebuilder:rbac:aggregate:to=view;edit
+// +kubebuilder:rbac:aggregate:to=admin,kinds=Gadget
+package aggregate
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas
+
+// Widget has both status and scale subresources.
+type Widget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WidgetSpec   `json:"spec"`
+	Status WidgetStatus `json:"status,omitempty"`
+}
+
+type WidgetSpec struct {
+	Replicas int32 `json:"replicas"`
+}
+
+type WidgetStatus struct {
+	Replicas int32 `json:"replicas"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=gizmos
+
+// Gadget has no subresources, and a custom plural.
+type Gadget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec string `json:"spec"`
+}
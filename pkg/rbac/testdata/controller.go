@@ -11,3 +11,11 @@ package controller
 // +kubebuilder:rbac:groups=batch,resources=jobs/status,verbs=watch;watch
 // +kubebuilder:rbac:groups=art,resources=jobs,verbs=get,namespace=park
 // +kubebuilder:rbac:groups=batch.io,resources=cronjobs,resourceNames=foo;bar;baz,verbs=get;watch
+
+// +kubebuilder:rbac:groups=art,resources=jobs,verbs=get;list,roleName=webhook-role
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations,verbs=get;update,roleName=webhook-role
+// +kubebuilder:rbac:groups=art,resources=jobs,verbs=get,namespace=zoo,roleName=webhook-role
+
+// CronJobReconciler carries the rules for what it does, next to it.
+// +kubebuilder:rbac:groups=batch.io,resources=cronjobs/finalizers,verbs=update
+type CronJobReconciler struct{}
@@ -0,0 +1,9 @@
+// Package v1 mimics a built-in Kubernetes API package, which declares its
+// group in a GroupName constant.
+package v1
+
+const GroupName = "apps"
+
+type Deployment struct {
+	Name string
+}
@@ -0,0 +1,25 @@
+// Package v1 mimics a built-in Kubernetes API package, which declares its
+// group in a GroupName constant.
+package v1
+
+const GroupName = ""
+
+type Pod struct {
+	Name string
+}
+
+type PodList struct {
+	Items []Pod
+}
+
+type ConfigMap struct {
+	Name string
+}
+
+type Endpoints struct {
+	Name string
+}
+
+type Secret struct {
+	Name string
+}
@@ -0,0 +1,20 @@
+// Package v1 is a kubebuilder-style API package, which declares its group
+// with a marker.
+// +groupName=things.example.com
+package v1
+
+// Widget uses the default plural.
+type Widget struct {
+	Name string
+}
+
+// +kubebuilder:resource:path=gizmos
+
+// Gadget has a custom plural.
+type Gadget struct {
+	Name string
+}
+
+type GadgetList struct {
+	Items []Gadget
+}
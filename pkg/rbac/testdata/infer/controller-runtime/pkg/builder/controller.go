@@ -0,0 +1,28 @@
+// Package builder is a minimal stand-in for controller-runtime's builder package.
+package builder
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+type Builder struct{}
+
+func ControllerManagedBy(mgr interface{}) *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) For(obj interface{}) *Builder {
+	return b
+}
+
+func (b *Builder) Owns(obj interface{}) *Builder {
+	return b
+}
+
+func (b *Builder) Watches(src source.Source, handler interface{}) *Builder {
+	return b
+}
+
+func (b *Builder) Complete(reconciler interface{}) error {
+	return nil
+}
@@ -0,0 +1,72 @@
+// Package client is a minimal stand-in for controller-runtime's client package,
+// with just enough API surface to exercise RBAC rule inference.
+package client
+
+import (
+	"context"
+)
+
+type Object interface{}
+
+type ObjectKey struct {
+	Namespace string
+	Name      string
+}
+
+type Option interface{}
+
+type Reader interface {
+	Get(ctx context.Context, key ObjectKey, obj Object) error
+	List(ctx context.Context, list Object, opts ...Option) error
+}
+
+type Writer interface {
+	Create(ctx context.Context, obj Object, opts ...Option) error
+	Delete(ctx context.Context, obj Object, opts ...Option) error
+	Update(ctx context.Context, obj Object, opts ...Option) error
+	Patch(ctx context.Context, obj Object, patch interface{}, opts ...Option) error
+	DeleteAllOf(ctx context.Context, obj Object, opts ...Option) error
+}
+
+type StatusClient interface {
+	Status() SubResourceWriter
+}
+
+// StatusWriter is what older versions of controller-runtime return from Status().
+type StatusWriter interface {
+	Update(ctx context.Context, obj Object, opts ...Option) error
+	Patch(ctx context.Context, obj Object, patch interface{}, opts ...Option) error
+}
+
+type SubResourceReader interface {
+	Get(ctx context.Context, obj Object, subResource Object, opts ...Option) error
+}
+
+type SubResourceWriter interface {
+	Create(ctx context.Context, obj Object, subResource Object, opts ...Option) error
+	Update(ctx context.Context, obj Object, opts ...Option) error
+	Patch(ctx context.Context, obj Object, patch interface{}, opts ...Option) error
+}
+
+type SubResourceClient interface {
+	SubResourceReader
+	SubResourceWriter
+}
+
+type SubResourceClientConstructor interface {
+	SubResource(subResource string) SubResourceClient
+}
+
+type Client interface {
+	Reader
+	Writer
+	StatusClient
+	SubResourceClientConstructor
+}
+
+// Cache has methods named like the client's, which don't need any permissions.
+type Cache struct{}
+
+func (Cache) Get(ctx context.Context, key ObjectKey, obj Object) error {
+	return nil
+}
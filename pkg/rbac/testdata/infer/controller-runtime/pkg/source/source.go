@@ -0,0 +1,8 @@
+// Package source is a minimal stand-in for controller-runtime's source package.
+package source
+
+type Source interface{}
+
+type Kind struct {
+	Type interface{}
+}
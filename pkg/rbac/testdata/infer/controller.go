@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appsv1 "testdata.kubebuilder.io/infer/apis/apps/v1"
+	corev1 "testdata.kubebuilder.io/infer/apis/core/v1"
+	thingsv1 "testdata.kubebuilder.io/infer/apis/things/v1"
+)
+
+// +kubebuilder:rbac:groups=things.example.com,resources=widgets,verbs=delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=create;delete
+
+type WidgetReconciler struct {
+	client.Client
+}
+
+func (r *WidgetReconciler) Reconcile(ctx context.Context, key client.ObjectKey) error {
+	var widget thingsv1.Widget
+	if err := r.Get(ctx, key, &widget); err != nil {
+		return err
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods); err != nil {
+		return err
+	}
+
+	if err := r.Create(ctx, &corev1.ConfigMap{Name: widget.Name}); err != nil {
+		return err
+	}
+
+	deploy := &appsv1.Deployment{Name: widget.Name}
+	if err := r.Patch(ctx, deploy, nil); err != nil {
+		return err
+	}
+
+	if err := r.DeleteAllOf(ctx, &thingsv1.Gadget{}); err != nil {
+		return err
+	}
+
+	// can't be resolved statically, so it's skipped
+	var obj client.Object = &corev1.Endpoints{}
+	if err := r.Update(ctx, obj); err != nil {
+		return err
+	}
+
+	return r.Status().Update(ctx, &widget)
+}
+
+func (r *WidgetReconciler) SetupWithManager(mgr interface{}) error {
+	return builder.ControllerManagedBy(mgr).
+		For(&thingsv1.Widget{}).
+		Owns(&appsv1.Deployment{}).
+		Watches(&source.Kind{Type: &thingsv1.GadgetList{}}, nil).
+		Complete(r)
+}
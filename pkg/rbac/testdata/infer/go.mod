@@ -0,0 +1,7 @@
+module testdata.kubebuilder.io/infer
+
+go 1.13
+
+require sigs.k8s.io/controller-runtime v0.0.0
+
+replace sigs.k8s.io/controller-runtime => ./controller-runtime
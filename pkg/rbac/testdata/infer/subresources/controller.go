@@ -0,0 +1,66 @@
+// Package subresources has calls on subresources, and calls on receivers that
+// aren't part of the client, and declares a kind of its own.
+// +groupName=subresources.example.com
+package subresources
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1 "testdata.kubebuilder.io/infer/apis/apps/v1"
+	corev1 "testdata.kubebuilder.io/infer/apis/core/v1"
+	thingsv1 "testdata.kubebuilder.io/infer/apis/things/v1"
+)
+
+// Doohickey is declared in the root package itself.
+type Doohickey struct {
+	Name string
+}
+
+type DoohickeyReconciler struct {
+	client.Client
+	cache client.Cache
+}
+
+func (r *DoohickeyReconciler) Reconcile(ctx context.Context, key client.ObjectKey) error {
+	var doohickey Doohickey
+	if err := r.Get(ctx, key, &doohickey); err != nil {
+		return err
+	}
+
+	// not a client call, so it doesn't need any permissions
+	if err := r.cache.Get(ctx, key, &corev1.Secret{}); err != nil {
+		return err
+	}
+
+	if err := r.Status().Patch(ctx, &doohickey, nil); err != nil {
+		return err
+	}
+
+	if err := r.SubResource("scale").Update(ctx, &appsv1.Deployment{}); err != nil {
+		return err
+	}
+
+	if err := r.SubResource("eviction").Create(ctx, &corev1.Pod{}, nil); err != nil {
+		return err
+	}
+
+	// the subresource can't be resolved statically, so it's skipped
+	if err := r.SubResource(key.Name).Update(ctx, &corev1.ConfigMap{}); err != nil {
+		return err
+	}
+
+	return updateStatus(ctx, r.Status(), &thingsv1.Gadget{})
+}
+
+// updateStatus writes the status through the writer returned by Status(),
+// without knowing which subresource it's for.
+func updateStatus(ctx context.Context, status client.SubResourceWriter, gadget *thingsv1.Gadget) error {
+	return status.Update(ctx, gadget)
+}
+
+// updateLegacyStatus writes the status the way older versions of controller-runtime do.
+func updateLegacyStatus(ctx context.Context, status client.StatusWriter, widget *thingsv1.Widget) error {
+	return status.Update(ctx, widget)
+}
@@ -0,0 +1,20 @@
+// Package kinderrors grants access to its own kinds, one of which can't
+// be turned into a schema, from each marker that needs to know about them.
+// +groupName=things.example.com
+// +kubebuilder:rbac:ownKinds=true
+// +kubebuilder:rbac:aggregate:to=view
+package kinderrors
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+
+// Widget has a map with non-string keys.
+type Widget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec map[int]string `json:"spec"`
+}
@@ -0,0 +1,7 @@
+package namespaces
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch,namespaces=team-a;team-b
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=update,namespace=team-a
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get,namespace=team-c,namespaces=team-a
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list,namespaceFromEnv=WATCH_NAMESPACE
+// +kubebuilder:rbac:groups=apps,resources=secrets,verbs=get,namespace=team-a,namespaceFromEnv=WATCH_NAMESPACE
@@ -0,0 +1,5 @@
+// Package otherroles only has rules for a separately named role, so nothing
+// gets bound to the generator's ServiceAccount.
+package otherroles
+
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get,roleName=webhook-role
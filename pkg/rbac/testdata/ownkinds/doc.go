@@ -0,0 +1,4 @@
+// Package ownkinds grants access to its own kinds twice, which shouldn't
+// change the generated rules.
+// +kubebuilder:rbac:ownKinds=true
+package ownkinds
@@ -0,0 +1,38 @@
+// +groupName=things.example.com
+// +kubebuilder:rbac:ownKinds=true
+package ownkinds
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Widget has a status subresource.
+type Widget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WidgetSpec   `json:"spec"`
+	Status WidgetStatus `json:"status,omitempty"`
+}
+
+type WidgetSpec struct {
+	Replicas int32 `json:"replicas"`
+}
+
+type WidgetStatus struct {
+	Replicas int32 `json:"replicas"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=gizmos
+
+// Gadget has no subresources, and a custom plural.
+type Gadget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec string `json:"spec"`
+}
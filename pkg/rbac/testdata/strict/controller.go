@@ -0,0 +1,14 @@
+package strict
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=*,resources=deployments,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=*
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;frobnicate
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get,urls=/metrics
+// +kubebuilder:rbac:groups=apps,resources=deployments,resourceNames=foo,verbs=get;create;list
+
+// +kubebuilder:rbac:urls=/healthz,verbs=get
+
+// Reconciler has a type-level marker that's rejected too.
+// +kubebuilder:rbac:groups=apps,resources=*,verbs=get
+type Reconciler struct{}
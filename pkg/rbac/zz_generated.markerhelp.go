@@ -24,22 +24,77 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
+func (Aggregate) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "RBAC",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "specifies that the default user-facing ClusterRoles (admin, edit and/or view) should be granted access to the kinds in this package. ",
+			Details: "A ClusterRole is generated for each role, labeled so that it's aggregated into that role.  View grants read-only access, while edit and admin grant full access.  The status and scale subresources are covered as well, if the kind has them.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"To": markers.DetailedHelp{
+				Summary: "specifies the roles to aggregate into (admin, edit or view).",
+				Details: "",
+			},
+			"Kinds": markers.DetailedHelp{
+				Summary: "specifies the kinds in this package to grant access to. ",
+				Details: "If not set, all kinds in the package are covered.",
+			},
+		},
+	}
+}
+
 func (Generator) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "",
 		DetailedHelp: markers.DetailedHelp{
-			Summary: "generates ClusterRole objects.",
-			Details: "",
+			Summary: "generates ClusterRole objects. ",
+			Details: "If ServiceAccount is set, it also generates the ServiceAccount, plus bindings granting it the generated ClusterRole and Roles named RoleName.",
 		},
 		FieldHelp: map[string]markers.DetailedHelp{
 			"RoleName": markers.DetailedHelp{
-				Summary: "sets the name of the generated ClusterRole.",
-				Details: "",
+				Summary: "sets the name of the generated ClusterRole. ",
+				Details: "Rules specifying their own roleName are generated into separately named roles instead, each written to their own file (<roleName>_role.yaml).",
+			},
+			"ServiceAccount": markers.DetailedHelp{
+				Summary: "sets the name of a ServiceAccount to generate and bind the generated roles to. ",
+				Details: "The ClusterRole is bound with a ClusterRoleBinding, and each Role with a RoleBinding in its namespace, all named after RoleName (with a \"-binding\" suffix).  The ServiceAccount and bindings are written to service_account.yaml and role_binding.yaml, respectively.  The ServiceAccount is generated even if there are no roles named RoleName to bind it to, in which case there's no role_binding.yaml.",
+			},
+			"ServiceAccountNamespace": markers.DetailedHelp{
+				Summary: "sets the namespace of the generated ServiceAccount. ",
+				Details: "It must be set if ServiceAccount is.",
+			},
+			"Infer": markers.DetailedHelp{
+				Summary: "adds the rules needed by the controller-runtime client calls and controller builder watches found in the code, on top of those specified with RBAC markers. ",
+				Details: "Calls whose objects can't be resolved statically (e.g. unstructured objects) are skipped, and still need markers.",
+			},
+			"Audit": markers.DetailedHelp{
+				Summary: "sets the name of a Markdown report to write, comparing the permissions granted by RBAC markers with the ones the code needs (as found by Infer, whether or not it's enabled). ",
+				Details: "The report lists permissions that are used but not granted, granted but unused, and both, each with the positions of the markers granting them and the calls using them.",
+			},
+			"Docs": markers.DetailedHelp{
+				Summary: "sets the name of a Markdown document to write, listing every generated rule with its API group, resource, subresource, verbs and scope (cluster or namespace), along with the positions of the RBAC markers (and inferred calls) it comes from. ",
+				Details: "The rules of aggregated roles are included, with the aggregate markers as their sources.",
+			},
+			"Strict": markers.DetailedHelp{
+				Summary: "fails generation for RBAC markers that are likely mistakes, or grant overly broad access. ",
+				Details: "That's markers using wildcards in groups, resources or verbs, using unknown verbs, mixing urls with groups or resources, or combining resourceNames with the create or list verbs (which can't be restricted by name).",
 			},
 		},
 	}
 }
 
+func (OwnKinds) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "RBAC",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "grants access to all the kinds in the root packages. ",
+			Details: "That's full access to each kind, update and patch on its status subresource (if it has one), and update on its finalizers (needed to block its deletion from owner references when the OwnerReferencesPermissionEnforcement admission plugin is enabled).",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
 func (Rule) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "RBAC",
@@ -72,6 +127,18 @@ func (Rule) Help() *markers.DefinitionHelp {
 				Summary: "specifies the scope of the Rule. If not set, the Rule belongs to the generated ClusterRole. If set, the Rule belongs to a Role, whose namespace is specified by this field.",
 				Details: "",
 			},
+			"Namespaces": markers.DetailedHelp{
+				Summary: "specifies several namespaces for the Rule at once. ",
+				Details: "The Rule belongs to a Role in each of these namespaces (plus the one specified by Namespace, if set), as if repeated for each namespace.",
+			},
+			"NamespaceFromEnv": markers.DetailedHelp{
+				Summary: "specifies an environment variable holding the namespace of the Rule, for namespaces only known at deploy time. ",
+				Details: "The Rule belongs to a single Role, whose namespace is a \"${VAR}\" placeholder to be filled in when deploying, e.g. with envsubst, or by replacing it in a kustomize or Helm template.  It can't be combined with Namespace or Namespaces.",
+			},
+			"RoleName": markers.DetailedHelp{
+				Summary: "specifies the name of the ClusterRole or Role this Rule belongs to. ",
+				Details: "If not set, the Rule belongs to the roles named after the generator's RoleName.  Roles with other names are written to their own files.",
+			},
 		},
 	}
 }
@@ -0,0 +1,363 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbacpatcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kyaml "sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	yamlop "sigs.k8s.io/controller-tools/pkg/internal/yaml"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sigs.k8s.io/controller-tools/pkg/rbac"
+)
+
+const (
+	// manualRulesBegin marks the start of hand-written rules, which are kept when patching.
+	manualRulesBegin = "+rbacpatch:manual:begin"
+	// manualRulesEnd marks the end of hand-written rules.
+	manualRulesEnd = "+rbacpatch:manual:end"
+)
+
+// +controllertools:marker:generateHelp
+
+// Generator patches the rules of existing ClusterRoles and Roles with the
+// ones generated from RBAC markers.
+//
+// Roles are found by kind, name and namespace in the YAML files in the
+// manifests directory.  Only their rules are replaced, so everything else
+// (labels, annotations, comments, etc) is kept.  Roles that don't already
+// exist aren't added, and files without generated roles aren't written.
+//
+// Hand-written rules can be kept by putting them at the end of the list,
+// between "# +rbacpatch:manual:begin" and "# +rbacpatch:manual:end" comments.
+type Generator struct {
+	// ManifestsPath contains the ClusterRole and Role YAML files.
+	ManifestsPath string `marker:"manifests"`
+
+	// RoleName sets the name of the roles to patch with rules that don't
+	// specify their own roleName, like the rbac generator's RoleName.
+	RoleName string
+}
+
+var _ genall.Generator = &Generator{}
+
+func (Generator) RegisterMarkers(into *markers.Registry) error {
+	return rbac.Generator{}.RegisterMarkers(into)
+}
+
+// roleKey identifies a ClusterRole or Role.
+type roleKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (g Generator) Generate(ctx *genall.GenerationContext) error {
+	rolesByName, err := rbac.GenerateNamedRoles(ctx, g.RoleName)
+	if err != nil {
+		return err
+	}
+
+	newRules := make(map[roleKey][]rbacv1.PolicyRule)
+	for _, roles := range rolesByName {
+		for _, role := range roles {
+			switch role := role.(type) {
+			case rbacv1.ClusterRole:
+				newRules[roleKey{Kind: "ClusterRole", Name: role.Name}] = role.Rules
+			case rbacv1.Role:
+				newRules[roleKey{Kind: "Role", Namespace: role.Namespace, Name: role.Name}] = role.Rules
+			}
+		}
+	}
+
+	files, err := ioutil.ReadDir(g.ManifestsPath)
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range files {
+		if fileInfo.IsDir() {
+			continue
+		}
+		if ext := filepath.Ext(fileInfo.Name()); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+
+		if err := g.patchFile(ctx, fileInfo.Name(), newRules); err != nil {
+			return fmt.Errorf("%s: %w", fileInfo.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// patchFile patches the rules of the roles in the given file (relative to
+// the manifests directory), writing it out if any were patched.
+func (g Generator) patchFile(ctx *genall.GenerationContext, fileName string, newRules map[roleKey][]rbacv1.PolicyRule) error {
+	rawContent, err := ctx.ReadFile(filepath.Join(g.ManifestsPath, fileName))
+	if err != nil {
+		return err
+	}
+
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(rawContent))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		docs = append(docs, &doc)
+	}
+
+	patched := false
+	for _, doc := range docs {
+		key, isRole, err := roleKeyFor(doc)
+		if err != nil {
+			return err
+		}
+		if !isRole {
+			continue
+		}
+		rules, generated := newRules[key]
+		if !generated {
+			continue
+		}
+		if err := setRules(doc, rules); err != nil {
+			return fmt.Errorf("unable to patch %s %s: %w", key.Kind, key.Name, err)
+		}
+		patched = true
+	}
+	if !patched {
+		return nil
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	// yaml.v2 defaults to indent=2, yaml.v3 defaults to indent=4,
+	// so be compatible with everything else in k8s and choose 2.
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	outWriter, err := ctx.Open(nil, fileName)
+	if err != nil {
+		return err
+	}
+	defer outWriter.Close()
+	_, err = outWriter.Write(out.Bytes())
+	return err
+}
+
+// roleKeyFor identifies the ClusterRole or Role in the given document,
+// returning false if the document isn't one.
+func roleKeyFor(doc *yaml.Node) (roleKey, bool, error) {
+	scalarAt := func(path ...string) (string, error) {
+		node, found, err := yamlop.GetNode(doc, path...)
+		if err != nil || !found {
+			return "", err
+		}
+		return node.Value, nil
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return roleKey{}, false, nil
+	}
+	apiVersion, err := scalarAt("apiVersion")
+	if err != nil {
+		return roleKey{}, false, err
+	}
+	if !strings.HasPrefix(apiVersion, rbacv1.GroupName+"/") {
+		return roleKey{}, false, nil
+	}
+
+	var key roleKey
+	if key.Kind, err = scalarAt("kind"); err != nil {
+		return roleKey{}, false, err
+	}
+	if key.Kind != "ClusterRole" && key.Kind != "Role" {
+		return roleKey{}, false, nil
+	}
+	if key.Name, err = scalarAt("metadata", "name"); err != nil {
+		return roleKey{}, false, err
+	}
+	if key.Kind == "Role" {
+		if key.Namespace, err = scalarAt("metadata", "namespace"); err != nil {
+			return roleKey{}, false, err
+		}
+	}
+	return key, true, nil
+}
+
+// setRules replaces the rules of the role in the given document with the
+// given ones, keeping any hand-written rules in the manual region.
+func setRules(doc *yaml.Node, rules []rbacv1.PolicyRule) error {
+	// round-trip through sigs.k8s.io/yaml to get the same (sorted) field
+	// order as the rbac generator.
+	rawRules, err := kyaml.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	var rulesDoc yaml.Node
+	if err := yaml.Unmarshal(rawRules, &rulesDoc); err != nil {
+		return err
+	}
+	newRules := rulesDoc.Content[0]
+	if len(rules) == 0 {
+		newRules = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	}
+
+	root := doc.Content[0]
+	rulesIdx := -1
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "rules" {
+			rulesIdx = i
+			break
+		}
+	}
+	if rulesIdx == -1 {
+		rulesKey := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "rules"}
+		root.Content = append(root.Content, rulesKey, newRules)
+		return nil
+	}
+	rulesKey, oldRules := root.Content[rulesIdx], root.Content[rulesIdx+1]
+
+	if oldRules.Kind == yaml.SequenceNode {
+		// an end marker at the end of the list gets attached to whatever
+		// comes after it, so clear it out from there
+		stripTrailingMarkers(oldRules)
+		rulesKey.FootComment, _, _ = stripRegionMarkers(rulesKey.FootComment)
+		root.FootComment, _, _ = stripRegionMarkers(root.FootComment)
+		doc.FootComment, _, _ = stripRegionMarkers(doc.FootComment)
+		if rulesIdx+2 < len(root.Content) {
+			nextKey := root.Content[rulesIdx+2]
+			nextKey.HeadComment, _, _ = stripRegionMarkers(nextKey.HeadComment)
+		}
+
+		// keep any comment leading the list
+		if len(oldRules.Content) > 0 && len(newRules.Content) > 0 {
+			if leading := leadingComment(oldRules.Content[0]); leading != "" && !strings.Contains(leading, manualRulesBegin) {
+				newRules.Content[0].HeadComment = leading
+			}
+		}
+
+		if manual := manualRules(oldRules); len(manual) > 0 {
+			// put the region markers back around the kept rules, at the end of the list
+			manual[0].HeadComment = joinComments("# "+manualRulesBegin, manual[0].HeadComment)
+			rulesKey.FootComment = joinComments(rulesKey.FootComment, "# "+manualRulesEnd)
+			newRules.Content = append(newRules.Content, manual...)
+		}
+	}
+
+	// keep the original node (and thus its comments) around
+	oldRules.Kind = newRules.Kind
+	oldRules.Tag = newRules.Tag
+	oldRules.Style = newRules.Style
+	oldRules.Content = newRules.Content
+	return nil
+}
+
+// leadingComment returns the comment before the given list item, moving it
+// onto the item itself if it's attached to the item's first key (which is
+// where comments before a mapping end up).
+func leadingComment(item *yaml.Node) string {
+	if item.Kind == yaml.MappingNode && len(item.Content) > 0 {
+		item.HeadComment = joinComments(item.HeadComment, item.Content[0].HeadComment)
+		item.Content[0].HeadComment = ""
+	}
+	return item.HeadComment
+}
+
+// manualRules returns the rules in the manual region of the given list of
+// rules, with the region markers stripped from their comments.
+func manualRules(rules *yaml.Node) []*yaml.Node {
+	var manual []*yaml.Node
+	inRegion := false
+	for _, item := range rules.Content {
+		var hasBegin, hasEnd bool
+		item.HeadComment, hasBegin, hasEnd = stripRegionMarkers(leadingComment(item))
+		if hasEnd {
+			inRegion = false
+		}
+		if hasBegin {
+			inRegion = true
+		}
+		if inRegion {
+			manual = append(manual, item)
+		}
+	}
+	return manual
+}
+
+// stripTrailingMarkers removes the manual region markers from the foot
+// comments of the given node and its last descendants.
+func stripTrailingMarkers(node *yaml.Node) {
+	for node != nil {
+		node.FootComment, _, _ = stripRegionMarkers(node.FootComment)
+		if len(node.Content) == 0 {
+			return
+		}
+		node = node.Content[len(node.Content)-1]
+	}
+}
+
+// stripRegionMarkers removes the lines containing manual region markers
+// from the given comment, noting which ones were present.
+func stripRegionMarkers(comment string) (string, bool, bool) {
+	if comment == "" {
+		return "", false, false
+	}
+	var hasBegin, hasEnd bool
+	var kept []string
+	for _, line := range strings.Split(comment, "\n") {
+		switch {
+		case strings.Contains(line, manualRulesBegin):
+			hasBegin = true
+		case strings.Contains(line, manualRulesEnd):
+			hasEnd = true
+		default:
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n"), hasBegin, hasEnd
+}
+
+// joinComments joins the given comments, skipping empty ones.
+func joinComments(comments ...string) string {
+	var nonEmpty []string
+	for _, comment := range comments {
+		if comment != "" {
+			nonEmpty = append(nonEmpty, comment)
+		}
+	}
+	return strings.Join(nonEmpty, "\n")
+}
@@ -0,0 +1,7 @@
+package controller
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get,namespace=system
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations,verbs=get;update,roleName=webhook-role
+// +kubebuilder:rbac:groups=things.example.com,resources=widgets,verbs=get,roleName=unmanaged-role
@@ -0,0 +1,45 @@
+// +build !ignore_autogenerated
+
+/*
+Copyright2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by helpgen. DO NOT EDIT.
+
+package rbacpatcher
+
+import (
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+func (Generator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "patches the rules of existing ClusterRoles and Roles with the ones generated from RBAC markers. ",
+			Details: "Roles are found by kind, name and namespace in the YAML files in the manifests directory.  Only their rules are replaced, so everything else (labels, annotations, comments, etc) is kept.  Roles that don't already exist aren't added, and files without generated roles aren't written. \n Hand-written rules can be kept by putting them at the end of the list, between \"# +rbacpatch:manual:begin\" and \"# +rbacpatch:manual:end\" comments.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"ManifestsPath": markers.DetailedHelp{
+				Summary: "contains the ClusterRole and Role YAML files.",
+				Details: "",
+			},
+			"RoleName": markers.DetailedHelp{
+				Summary: "sets the name of the roles to patch with rules that don't specify their own roleName, like the rbac generator's RoleName.",
+				Details: "",
+			},
+		},
+	}
+}
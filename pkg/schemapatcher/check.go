@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemapatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	yamlop "sigs.k8s.io/controller-tools/pkg/internal/yaml"
+)
+
+// checkSchemata compares the patched schemata of each of the given CRDs
+// against the ones originally read from disk, returning an error listing
+// the differences for each group-kind and version, if any.
+func checkSchemata(sets map[schema.GroupKind]*partialCRDSet) error {
+	groupKinds := make([]schema.GroupKind, 0, len(sets))
+	for groupKind := range sets {
+		groupKinds = append(groupKinds, groupKind)
+	}
+	sort.Slice(groupKinds, func(i, j int) bool { return groupKinds[i].String() < groupKinds[j].String() })
+
+	var report strings.Builder
+	outOfDate := 0
+	for _, groupKind := range groupKinds {
+		for _, crd := range sets[groupKind].CRDVersions {
+			drift, err := crd.schemaDrift()
+			if err != nil {
+				return fmt.Errorf("unable to check %s: %w", crd.File.FileName, err)
+			}
+			versions := make([]string, 0, len(drift))
+			for ver := range drift {
+				versions = append(versions, ver)
+			}
+			sort.Strings(versions)
+			for _, ver := range versions {
+				outOfDate++
+				fmt.Fprintf(&report, "\n%s, version %s (%s):\n", groupKind, ver, crd.File.FileName)
+				for _, line := range drift[ver] {
+					fmt.Fprintf(&report, "  %s\n", line)
+				}
+			}
+		}
+	}
+
+	if outOfDate == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d CRD schema(ta) out of date with the Go types:\n%s", outOfDate, report.String())
+}
+
+// schemaDrift computes the differences between the original and patched
+// schemata of each version of this CRD, omitting versions without any.
+func (e *partialCRD) schemaDrift() (map[string][]string, error) {
+	rawJSON, err := yamlop.ToJSON(e.Yaml)
+	if err != nil {
+		return nil, err
+	}
+	var patched crdIsh
+	if err := json.Unmarshal(rawJSON, &patched); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]string)
+	for ver, newSchema := range patched.schemata() {
+		var diffs []string
+		diffValues("", e.OrigSchemata[ver], newSchema, &diffs)
+		if len(diffs) > 0 {
+			res[ver] = diffs
+		}
+	}
+	return res, nil
+}
+
+// diffValues appends a line to diffs for each difference between the given
+// generic (JSON-style) values, prefixed by their path: "+" marks additions,
+// "-" removals, and "~" changed values.
+func diffValues(path string, oldVal, newVal interface{}, diffs *[]string) {
+	switch {
+	case oldVal == nil && newVal == nil:
+		return
+	case oldVal == nil:
+		*diffs = append(*diffs, "+ "+displayPath(path)+displayScalar(newVal))
+		return
+	case newVal == nil:
+		*diffs = append(*diffs, "- "+displayPath(path)+displayScalar(oldVal))
+		return
+	}
+
+	switch oldTyped := oldVal.(type) {
+	case map[string]interface{}:
+		newTyped, isMap := newVal.(map[string]interface{})
+		if !isMap {
+			break
+		}
+		keys := make([]string, 0, len(oldTyped)+len(newTyped))
+		for key := range oldTyped {
+			keys = append(keys, key)
+		}
+		for key := range newTyped {
+			if _, inOld := oldTyped[key]; !inOld {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			diffValues(path+"."+key, oldTyped[key], newTyped[key], diffs)
+		}
+		return
+	case []interface{}:
+		newTyped, isSlice := newVal.([]interface{})
+		if !isSlice {
+			break
+		}
+		for i := 0; i < len(oldTyped) || i < len(newTyped); i++ {
+			var oldItem, newItem interface{}
+			if i < len(oldTyped) {
+				oldItem = oldTyped[i]
+			}
+			if i < len(newTyped) {
+				newItem = newTyped[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), oldItem, newItem, diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(oldVal, newVal) {
+		*diffs = append(*diffs, fmt.Sprintf("~ %s: %v -> %v", displayPath(path), oldVal, newVal))
+	}
+}
+
+// displayPath formats a path computed by diffValues for display.
+func displayPath(path string) string {
+	if path == "" {
+		return "(schema)"
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+// displayScalar formats an added or removed value for display after its
+// path, skipping maps and slices (the path is enough to identify those).
+func displayScalar(val interface{}) string {
+	switch val.(type) {
+	case map[string]interface{}, []interface{}:
+		return ""
+	default:
+		return fmt.Sprintf(": %v", val)
+	}
+}
@@ -17,9 +17,13 @@ limitations under the License.
 package schemapatcher
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -32,9 +36,9 @@ import (
 	crdgen "sigs.k8s.io/controller-tools/pkg/crd"
 	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
 	"sigs.k8s.io/controller-tools/pkg/genall"
+	yamlop "sigs.k8s.io/controller-tools/pkg/internal/yaml"
 	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
-	yamlop "sigs.k8s.io/controller-tools/pkg/schemapatcher/internal/yaml"
 )
 
 // NB(directxman12): this code is quite fragile, but there are a sufficient
@@ -66,16 +70,23 @@ var (
 //
 // For legacy (v1beta1) multi-version CRDs, and any v1 CRDs, it will replace
 // schemata of existing versions and *clear the schema* from any versions not
-// specified in the Go code.  It will *not* add new versions, or remove old
-// ones.
+// specified in the Go code.  It will *not* remove old versions, and will only
+// add new ones if AddVersions is set.
 //
 // For legacy multi-version CRDs with identical schemata, it will take care of
 // lifting the per-version schema up to the global schema.
 //
+// If PatchExtras is set, it will also patch printer columns, subresources,
+// scope and names from the markers, treating them like schemata: they're
+// replaced on versions present in the Go code, and cleared from the rest.
+//
 // It will generate output for each "CRD Version" (API version of the CRD type
 // itself) , e.g. apiextensions/v1beta1 and apiextensions/v1) available.
 type Generator struct {
 	// ManifestsPath contains the CustomResourceDefinition YAML files.
+	//
+	// Files may also be JSON, or contain several YAML documents, in which
+	// case every CRD in them is patched, and other objects are left as-is.
 	ManifestsPath string `marker:"manifests"`
 
 	// MaxDescLen specifies the maximum description length for fields in CRD's OpenAPI schema.
@@ -84,6 +95,29 @@ type Generator struct {
 	// n indicates limit the description to at most n characters and truncate the description to
 	// closest sentence boundary if it exceeds n characters.
 	MaxDescLen *int `marker:",optional"`
+
+	// AddVersions indicates that versions present in the Go code, but not
+	// in the existing CRDs, should be added to them.
+	//
+	// New versions are appended after the existing ones, and are served
+	// unless marked with `+kubebuilder:unservedversion`.  If a new version
+	// is marked with `+kubebuilder:storageversion`, it replaces the existing
+	// storage version.
+	AddVersions bool `marker:",optional"`
+
+	// PatchExtras indicates that printer columns, subresources (status and
+	// scale), scope and names (including short names and categories) should
+	// be patched from the markers in addition to the schemata.
+	//
+	// Since the plural name determines the name of the CRD, the CRD's
+	// metadata.name is patched to match.
+	PatchExtras bool `marker:",optional"`
+
+	// Check indicates that, instead of writing out the patched CRDs, the
+	// schemata that would be written should be compared against the
+	// existing ones, failing with a diff for each group-kind and version
+	// that's out of date.  Nothing gets written in this mode.
+	Check bool `marker:",optional"`
 }
 
 var _ genall.Generator = &Generator{}
@@ -114,7 +148,7 @@ func (g Generator) Generate(ctx *genall.GenerationContext) (result error) {
 	}
 
 	// load existing CRD manifests with group-kind and versions
-	partialCRDSets, err := crdsFromDirectory(ctx, g.ManifestsPath)
+	partialCRDSets, manifestFiles, err := crdsFromDirectory(ctx, g.ManifestsPath)
 	if err != nil {
 		return err
 	}
@@ -126,15 +160,30 @@ func (g Generator) Generate(ctx *genall.GenerationContext) (result error) {
 			continue
 		}
 
+		if g.PatchExtras {
+			parser.NeedCRDFor(groupKind, nil)
+			if newCRD, generated := parser.CustomResourceDefinitions[groupKind]; generated {
+				existingSet.NewCRD = &newCRD
+			}
+		}
+
 		for pkg, gv := range parser.GroupVersions {
 			if gv.Group != groupKind.Group {
 				continue
 			}
+			typeIdent := crdgen.TypeIdent{Package: pkg, Name: groupKind.Kind}
 			if _, wantedVersion := existingSet.Versions[gv.Version]; !wantedVersion {
-				continue
+				if !g.AddVersions || parser.Types[typeIdent] == nil {
+					continue
+				}
+				parser.NeedCRDFor(groupKind, nil)
+				for _, ver := range parser.CustomResourceDefinitions[groupKind].Spec.Versions {
+					if ver.Name == gv.Version {
+						existingSet.AddedVersions = append(existingSet.AddedVersions, ver)
+					}
+				}
 			}
 
-			typeIdent := crdgen.TypeIdent{Package: pkg, Name: groupKind.Kind}
 			parser.NeedFlattenedSchemaFor(typeIdent)
 
 			fullSchema := parser.FlattenedSchemata[typeIdent]
@@ -155,6 +204,10 @@ func (g Generator) Generate(ctx *genall.GenerationContext) (result error) {
 			continue
 		}
 
+		if err := existingSet.addVersions(); err != nil {
+			return fmt.Errorf("failed to add versions to %s: %w", existingSet.GroupKind, err)
+		}
+
 		// copy over the new versions that we have, keeping old versions so
 		// that we can tell if a schema would be nil
 		var someVer string
@@ -182,37 +235,97 @@ func (g Generator) Generate(ctx *genall.GenerationContext) (result error) {
 				return fmt.Errorf("failed to set versioned schemas for %s: %w", existingSet.GroupKind, err)
 			}
 		}
-	}
 
-	// write the final result out to the new location
-	for _, set := range partialCRDSets {
-		// We assume all CRD versions came from different files, since this
-		// is how controller-gen works.  If they came from the same file,
-		// it'd be non-sensical, since you couldn't reasonably use kubectl
-		// with them against older servers.
-		for _, crd := range set.CRDVersions {
-			if err := func() error {
-				outWriter, err := ctx.OutputRule.Open(nil, crd.FileName)
-				if err != nil {
-					return err
-				}
-				defer outWriter.Close()
+		if existingSet.NewCRD != nil {
+			if err := existingSet.setExtras(); err != nil {
+				return fmt.Errorf("failed to set printer columns, subresources and names for %s: %w", existingSet.GroupKind, err)
+			}
+		}
+	}
 
-				enc := yaml.NewEncoder(outWriter)
-				// yaml.v2 defaults to indent=2, yaml.v3 defaults to indent=4,
-				// so be compatible with everything else in k8s and choose 2.
-				enc.SetIndent(2)
+	if g.Check {
+		return checkSchemata(partialCRDSets)
+	}
 
-				return enc.Encode(crd.Yaml)
-			}(); err != nil {
+	// write the final result out to the new location
+	for _, file := range manifestFiles {
+		if err := func() error {
+			outWriter, err := ctx.OutputRule.Open(nil, file.FileName)
+			if err != nil {
 				return err
 			}
+			defer outWriter.Close()
+
+			out, err := file.encode()
+			if err != nil {
+				return fmt.Errorf("%s: %w", file.FileName, err)
+			}
+			_, err = outWriter.Write(out)
+			return err
+		}(); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// manifestFile is a file containing one or more CRDs, possibly alongside
+// other objects, which gets written back out in its entirety after patching.
+//
+// We assume that different CRD versions of the same CRD generally come from
+// different files, since that's how controller-gen works, but bundles that
+// concatenate several CRDs into one stream are fine too.
+type manifestFile struct {
+	// FileName is the name of the file, relative to the manifests directory.
+	FileName string
+	// Documents are the documents in this file, in order.
+	Documents []*yaml.Node
+	// JSON indicates that this file was written in JSON (and thus contains
+	// a single document), and should be written back out as such.
+	JSON bool
+	// Templates are the template directives (e.g. from Helm charts) masked
+	// out of the original file, to be restored when writing (nil if the
+	// file had none).
+	Templates *templateMask
+}
+
+// encode serializes the (patched) documents in this file, in the same
+// format they were read in.
+func (f *manifestFile) encode() ([]byte, error) {
+	var out bytes.Buffer
+	if f.JSON {
+		for _, doc := range f.Documents {
+			rawJSON, err := yamlop.ToJSON(doc)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Indent(&out, rawJSON, "", "  "); err != nil {
+				return nil, err
+			}
+			out.WriteString("\n")
+		}
+	} else {
+		enc := yaml.NewEncoder(&out)
+		// yaml.v2 defaults to indent=2, yaml.v3 defaults to indent=4,
+		// so be compatible with everything else in k8s and choose 2.
+		enc.SetIndent(2)
+		for _, doc := range f.Documents {
+			if err := enc.Encode(doc); err != nil {
+				return nil, err
+			}
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.Templates == nil {
+		return out.Bytes(), nil
+	}
+	return f.Templates.restore(out.Bytes())
+}
+
 // partialCRDSet represents a set of CRDs of different apiext versions
 // (v1beta1.CRD vs v1.CRD) that represent the same GroupKind.
 //
@@ -229,6 +342,13 @@ type partialCRDSet struct {
 	CRDVersions []*partialCRD
 	// Versions are the versions of the given GroupKind in this set of CRDs.
 	Versions map[string]struct{}
+	// AddedVersions are the versions from the Go code that need to be added
+	// to this set of CRDs (only populated if adding versions is enabled).
+	AddedVersions []apiext.CustomResourceDefinitionVersion
+	// NewCRD is the full CRD generated from Go IDL by controller-gen, used
+	// as the source of everything but schemata (only populated if patching
+	// extras is enabled).
+	NewCRD *apiext.CustomResourceDefinition
 }
 
 // partialCRD represents the raw YAML encoding of a given CRD instance, plus
@@ -236,16 +356,118 @@ type partialCRDSet struct {
 type partialCRD struct {
 	// Yaml is the raw YAML structure of the CRD.
 	Yaml *yaml.Node
-	// FileName is the source name of the file that this was read from.
+	// File is the file that this was read from.
 	//
 	// This isn't on partialCRDSet because we could have different CRD versions
-	// stored in the same file (like controller-tools does by default) or in
-	// different files.
-	FileName string
+	// stored in the same file or in different files (like controller-tools
+	// does by default).
+	File *manifestFile
 
 	// CRDVersion is the version of the CRD object itself, from
 	// apiextensions (currently apiextensions/v1 or apiextensions/v1beta1).
 	CRDVersion string
+
+	// OrigSchemata are the schemata of each version as originally read
+	// (in generic form), for comparison in check mode.
+	OrigSchemata map[string]interface{}
+}
+
+// addVersions adds the AddedVersions to each encoding in this set,
+// as per addVersions on partialCRD.
+func (e *partialCRDSet) addVersions() error {
+	if len(e.AddedVersions) == 0 {
+		return nil
+	}
+	// keep the same ordering as the crd generator
+	sort.Slice(e.AddedVersions, func(i, j int) bool { return e.AddedVersions[i].Name < e.AddedVersions[j].Name })
+	for _, crdInfo := range e.CRDVersions {
+		if err := crdInfo.addVersions(e.AddedVersions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// versionEntry is the skeleton of a new entry in .spec.versions (the schema
+// gets filled in later, like for existing versions).
+type versionEntry struct {
+	Name    string                           `json:"name"`
+	Served  bool                             `json:"served"`
+	Storage bool                             `json:"storage"`
+	Schema  *apiext.CustomResourceValidation `json:"schema"`
+}
+
+// addVersions appends entries for the given versions to .spec.versions,
+// creating it from .spec.version for legacy single-version CRDs.  If one
+// of the new versions is the storage version, existing versions are marked
+// as non-storage versions.
+func (e *partialCRD) addVersions(newVersions []apiext.CustomResourceDefinitionVersion) error {
+	versions, found, err := e.getVersionsNode()
+	if err != nil {
+		return err
+	}
+	if !found {
+		if e.CRDVersion != legacyAPIExtVersion {
+			return fmt.Errorf("unexpected missing versions")
+		}
+		nameNode, found, err := yamlop.GetNode(e.Yaml, "spec", "version")
+		if err != nil {
+			return err
+		}
+		if !found || nameNode.Kind != yaml.ScalarNode {
+			return fmt.Errorf("legacy CRD has neither versions nor version")
+		}
+		existingVer, err := yamlop.ToYAML([]versionEntry{{
+			Name:    nameNode.Value,
+			Served:  true,
+			Storage: true,
+			Schema:  &apiext.CustomResourceValidation{OpenAPIV3Schema: &apiext.JSONSchemaProps{}},
+		}})
+		if err != nil {
+			return err
+		}
+		versions = existingVer.Content[0] // get rid of the document node
+		yamlop.SetStyle(versions, 0)      // clear the style so it defaults to an auto-chosen one
+		specNode, _, err := yamlop.GetNode(e.Yaml, "spec")
+		if err != nil {
+			return err
+		}
+		specNode.Content = append(specNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "versions"}, versions)
+	}
+
+	newStorage := false
+	for _, ver := range newVersions {
+		newStorage = newStorage || ver.Storage
+	}
+	if newStorage {
+		for i, verNode := range versions.Content {
+			storageNode, err := yamlop.ValueInMapping(verNode, "storage")
+			if err != nil {
+				return fmt.Errorf("spec.versions[%d]: %w", i, err)
+			}
+			if storageNode == nil {
+				storageNode = &yaml.Node{}
+				verNode.Content = append(verNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "storage"}, storageNode)
+			}
+			*storageNode = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
+		}
+	}
+
+	for _, ver := range newVersions {
+		verNode, err := yamlop.ToYAML(versionEntry{
+			Name:    ver.Name,
+			Served:  ver.Served,
+			Storage: ver.Storage,
+			Schema:  &apiext.CustomResourceValidation{OpenAPIV3Schema: &apiext.JSONSchemaProps{}},
+		})
+		if err != nil {
+			return err
+		}
+		verNode = verNode.Content[0] // get rid of the document node
+		yamlop.SetStyle(verNode, 0)  // clear the style so it defaults to an auto-chosen one
+		versions.Content = append(versions.Content, verNode)
+	}
+	return nil
 }
 
 // setGlobalSchema sets the global schema for the v1beta1 apiext version in
@@ -394,78 +616,312 @@ func (e *partialCRD) setVersionedSchemata(newSchemata map[string]apiext.JSONSche
 	return nil
 }
 
+// setExtras sets the printer columns, subresources, scope and names on each
+// encoding in this set as per setExtras on partialCRD.
+func (e *partialCRDSet) setExtras() error {
+	// only consider versions we actually have, so that identical extras
+	// get lifted to the top level of legacy CRDs like they would've been
+	// had the CRD been generated with only those versions.
+	newCRD := e.NewCRD.DeepCopy()
+	newCRD.Spec.Versions = nil
+	for _, ver := range e.NewCRD.Spec.Versions {
+		if _, present := e.Versions[ver.Name]; present {
+			newCRD.Spec.Versions = append(newCRD.Spec.Versions, ver)
+		}
+	}
+	if len(newCRD.Spec.Versions) == 0 {
+		return nil
+	}
+
+	for _, crdInfo := range e.CRDVersions {
+		if err := crdInfo.setExtras(*newCRD); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// crdExtras holds the parts of a CRD besides the schemata that get patched
+// when patching extras, in a form that works for both v1 and v1beta1 CRDs
+// (fields that don't exist in a given CRD version are simply left empty).
+//
+// Values are left in generic form, since we just convert them back to YAML.
+type crdExtras struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Names          map[string]interface{} `json:"names"`
+		Scope          string                 `json:"scope"`
+		Subresources   interface{}            `json:"subresources"`
+		PrinterColumns interface{}            `json:"additionalPrinterColumns"`
+		Versions       []struct {
+			Name           string      `json:"name"`
+			Subresources   interface{} `json:"subresources"`
+			PrinterColumns interface{} `json:"additionalPrinterColumns"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// crdNameFields are the fields of .spec.names that get patched, in the order
+// they're added in if not present already.
+var crdNameFields = []string{"kind", "listKind", "plural", "singular", "shortNames", "categories"}
+
+// setExtras replaces the printer columns, subresources, scope and names of
+// this CRD with those of the given CRD, converting to the legacy form first
+// if necessary.  Like with schemata, printer columns and subresources are
+// cleared from any versions not in the given CRD.
+func (e *partialCRD) setExtras(newCRD apiext.CustomResourceDefinition) error {
+	var converted interface{} = &newCRD
+	if e.CRDVersion == legacyAPIExtVersion {
+		var err error
+		converted, err = crdgen.AsVersion(newCRD, apiextlegacy.SchemeGroupVersion)
+		if err != nil {
+			return fmt.Errorf("failed to convert CRD to legacy form: %w", err)
+		}
+	}
+	rawExtras, err := json.Marshal(converted)
+	if err != nil {
+		return err
+	}
+	var extras crdExtras
+	if err := json.Unmarshal(rawExtras, &extras); err != nil {
+		return err
+	}
+
+	metaNode, found, err := yamlop.GetNode(e.Yaml, "metadata")
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("unexpected missing metadata")
+	}
+	if err := setInMapping(metaNode, "name", extras.Metadata.Name); err != nil {
+		return fmt.Errorf("metadata: %w", err)
+	}
+
+	specNode, found, err := yamlop.GetNode(e.Yaml, "spec")
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("unexpected missing spec")
+	}
+	if err := setInMapping(specNode, "scope", extras.Spec.Scope); err != nil {
+		return fmt.Errorf("spec: %w", err)
+	}
+	if err := setInMapping(specNode, "subresources", extras.Spec.Subresources); err != nil {
+		return fmt.Errorf("spec: %w", err)
+	}
+	if err := setInMapping(specNode, "additionalPrinterColumns", extras.Spec.PrinterColumns); err != nil {
+		return fmt.Errorf("spec: %w", err)
+	}
+
+	namesNode, err := yamlop.ValueInMapping(specNode, "names")
+	if err != nil {
+		return fmt.Errorf("spec: %w", err)
+	}
+	if namesNode == nil {
+		return fmt.Errorf("unexpected missing names")
+	}
+	for _, field := range crdNameFields {
+		if err := setInMapping(namesNode, field, extras.Spec.Names[field]); err != nil {
+			return fmt.Errorf("spec.names: %w", err)
+		}
+	}
+
+	versions, found, err := e.getVersionsNode()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	for i, verNode := range versions.Content {
+		nameNode, _, _ := yamlop.GetNode(verNode, "name")
+		if nameNode == nil || nameNode.Kind != yaml.ScalarNode {
+			return fmt.Errorf("version name was not a string at spec.versions[%d]", i)
+		}
+		var subresources, columns interface{}
+		for _, ver := range extras.Spec.Versions {
+			if ver.Name == nameNode.Value {
+				subresources, columns = ver.Subresources, ver.PrinterColumns
+				break
+			}
+		}
+		if err := setInMapping(verNode, "subresources", subresources); err != nil {
+			return fmt.Errorf("spec.versions[%d]: %w", i, err)
+		}
+		if err := setInMapping(verNode, "additionalPrinterColumns", columns); err != nil {
+			return fmt.Errorf("spec.versions[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// setInMapping sets the given key in the given mapping node to the YAML form
+// of the given value, keeping the key's position if it already exists.  A nil
+// value removes the key instead.
+//
+// Unlike yamlop.SetNode, new keys are left unquoted, like the rest of a
+// typical hand-written CRD.
+func setInMapping(mapping *yaml.Node, key string, val interface{}) error {
+	if val == nil {
+		return yamlop.DeleteNode(mapping, key)
+	}
+
+	valNode, err := yamlop.ToYAML(val)
+	if err != nil {
+		return err
+	}
+	valNode = valNode.Content[0] // get rid of the document node
+	yamlop.SetStyle(valNode, 0)  // clear the style so it defaults to an auto-chosen one
+
+	existing, err := yamlop.ValueInMapping(mapping, key)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		*existing = *valNode
+		return nil
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valNode)
+	return nil
+}
+
 // crdsFromDirectory returns loads all CRDs from the given directory in a
 // manner that preserves ordering, comments, etc in order to make patching
-// minimally invasive.  Returned CRDs are mapped by group-kind.
-func crdsFromDirectory(ctx *genall.GenerationContext, dir string) (map[schema.GroupKind]*partialCRDSet, error) {
+// minimally invasive.  Returned CRDs are mapped by group-kind, and are
+// accompanied by the files that contain them.
+func crdsFromDirectory(ctx *genall.GenerationContext, dir string) (map[schema.GroupKind]*partialCRDSet, []*manifestFile, error) {
 	res := map[schema.GroupKind]*partialCRDSet{}
+	var files []*manifestFile
 	dirEntries, err := ioutil.ReadDir(dir)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, fileInfo := range dirEntries {
-		// find all files that are YAML
-		if fileInfo.IsDir() || filepath.Ext(fileInfo.Name()) != ".yaml" {
+		// find all files that are YAML (or JSON, which is YAML too)
+		if fileInfo.IsDir() || !isManifestExt(filepath.Ext(fileInfo.Name())) {
 			continue
 		}
 
 		rawContent, err := ctx.ReadFile(filepath.Join(dir, fileInfo.Name()))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		// NB(directxman12): we could use the universal deserializer for this, but it's
-		// really pretty clunky, and the alternative is actually kinda easier to understand
+		// mask out any template directives so that templated manifests
+		// (e.g. from Helm charts) parse as YAML
+		rawContent, templates := maskTemplates(rawContent)
 
-		// ensure that this is a CRD
-		var typeMeta metav1.TypeMeta
-		if err := kyaml.Unmarshal(rawContent, &typeMeta); err != nil {
-			continue
+		// unmarshal in a manner that preserves ordering, etc, keeping around
+		// all documents (even non-CRD ones) so that we can write them back out
+		file := &manifestFile{
+			FileName:  fileInfo.Name(),
+			JSON:      filepath.Ext(fileInfo.Name()) == ".json",
+			Templates: templates,
 		}
-		if !isSupportedAPIExtGroupVer(typeMeta.APIVersion) || typeMeta.Kind != "CustomResourceDefinition" {
-			continue
+		dec := yaml.NewDecoder(bytes.NewReader(rawContent))
+		for {
+			var doc yaml.Node
+			if err := dec.Decode(&doc); err == io.EOF {
+				break
+			} else if err != nil {
+				// not something we can parse, so we can't patch it anyway
+				file.Documents = nil
+				break
+			}
+			file.Documents = append(file.Documents, &doc)
 		}
 
-		// collect the group-kind and versions from the actual structured form
-		var actualCRD crdIsh
-		if err := kyaml.Unmarshal(rawContent, &actualCRD); err != nil {
-			continue
-		}
-		groupKind := schema.GroupKind{Group: actualCRD.Spec.Group, Kind: actualCRD.Spec.Names.Kind}
-		var versions map[string]struct{}
-		if len(actualCRD.Spec.Versions) == 0 {
-			versions = map[string]struct{}{actualCRD.Spec.Version: struct{}{}}
-		} else {
-			versions = make(map[string]struct{}, len(actualCRD.Spec.Versions))
-			for _, ver := range actualCRD.Spec.Versions {
-				versions[ver.Name] = struct{}{}
+		hasCRDs := false
+		for _, doc := range file.Documents {
+			crd, groupKind, versions, err := partialCRDFrom(doc)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", fileInfo.Name(), err)
+			}
+			if crd == nil {
+				continue
 			}
+			hasCRDs = true
+			crd.File = file
+
+			// then store this CRDVersion of the CRD in a set, populating the set if necessary
+			if res[groupKind] == nil {
+				res[groupKind] = &partialCRDSet{
+					GroupKind:   groupKind,
+					NewSchemata: make(map[string]apiext.JSONSchemaProps),
+					Versions:    make(map[string]struct{}),
+				}
+			}
+			for ver := range versions {
+				res[groupKind].Versions[ver] = struct{}{}
+			}
+			res[groupKind].CRDVersions = append(res[groupKind].CRDVersions, crd)
 		}
-
-		// then actually unmarshal in a manner that preserves ordering, etc
-		var yamlNodeTree yaml.Node
-		if err := yaml.Unmarshal(rawContent, &yamlNodeTree); err != nil {
-			continue
+		if hasCRDs {
+			files = append(files, file)
 		}
+	}
+	return res, files, nil
+}
 
-		// then store this CRDVersion of the CRD in a set, populating the set if necessary
-		if res[groupKind] == nil {
-			res[groupKind] = &partialCRDSet{
-				GroupKind:   groupKind,
-				NewSchemata: make(map[string]apiext.JSONSchemaProps),
-				Versions:    make(map[string]struct{}),
-			}
-		}
-		for ver := range versions {
-			res[groupKind].Versions[ver] = struct{}{}
+// partialCRDFrom constructs a partialCRD from the given YAML document, if it
+// contains a CRD, returning its group-kind and versions as well.  Documents
+// that aren't CRDs yield a nil partialCRD.
+func partialCRDFrom(doc *yaml.Node) (*partialCRD, schema.GroupKind, map[string]struct{}, error) {
+	if len(doc.Content) == 0 {
+		// empty document
+		return nil, schema.GroupKind{}, nil, nil
+	}
+
+	// NB(directxman12): we could use the universal deserializer for this, but it's
+	// really pretty clunky, and the alternative is actually kinda easier to understand
+
+	// NB: don't use yaml.Marshal here, since it shuffles comments around in the
+	// node tree as a side effect
+	rawContent, err := yamlop.ToJSON(doc)
+	if err != nil {
+		return nil, schema.GroupKind{}, nil, nil
+	}
+
+	// ensure that this is a CRD
+	var typeMeta metav1.TypeMeta
+	if err := kyaml.Unmarshal(rawContent, &typeMeta); err != nil {
+		return nil, schema.GroupKind{}, nil, nil
+	}
+	if !isSupportedAPIExtGroupVer(typeMeta.APIVersion) || typeMeta.Kind != "CustomResourceDefinition" {
+		return nil, schema.GroupKind{}, nil, nil
+	}
+
+	// collect the group-kind and versions from the actual structured form
+	var actualCRD crdIsh
+	if err := kyaml.Unmarshal(rawContent, &actualCRD); err != nil {
+		return nil, schema.GroupKind{}, nil, nil
+	}
+	groupKind := schema.GroupKind{Group: actualCRD.Spec.Group, Kind: actualCRD.Spec.Names.Kind}
+	var versions map[string]struct{}
+	if len(actualCRD.Spec.Versions) == 0 {
+		versions = map[string]struct{}{actualCRD.Spec.Version: struct{}{}}
+	} else {
+		versions = make(map[string]struct{}, len(actualCRD.Spec.Versions))
+		for _, ver := range actualCRD.Spec.Versions {
+			versions[ver.Name] = struct{}{}
 		}
-		res[groupKind].CRDVersions = append(res[groupKind].CRDVersions, &partialCRD{
-			Yaml:       &yamlNodeTree,
-			FileName:   fileInfo.Name(),
-			CRDVersion: typeMeta.APIVersion,
-		})
 	}
-	return res, nil
+
+	return &partialCRD{
+		Yaml:         doc,
+		CRDVersion:   typeMeta.APIVersion,
+		OrigSchemata: actualCRD.schemata(),
+	}, groupKind, versions, nil
+}
+
+// isManifestExt checks if the given file extension is one of a manifest
+// that we know how to read.
+func isManifestExt(ext string) bool {
+	return ext == ".yaml" || ext == ".yml" || ext == ".json"
 }
 
 // isSupportedAPIExtGroupVer checks if the given string-form group-version
@@ -489,12 +945,41 @@ type crdIsh struct {
 			Kind string `json:"kind"`
 		} `json:"names"`
 		Versions []struct {
-			Name string `json:"name"`
+			Name   string         `json:"name"`
+			Schema *validationIsh `json:"schema"`
 		} `json:"versions"`
-		Version string `json:"version"`
+		Version    string         `json:"version"`
+		Validation *validationIsh `json:"validation"`
 	} `json:"spec"`
 }
 
+// validationIsh is the schema wrapper of both CRD versions, with the
+// schema itself left in generic form.
+type validationIsh struct {
+	OpenAPIV3Schema interface{} `json:"openAPIV3Schema"`
+}
+
+// schemata returns the effective schema of each version of this CRD,
+// falling back to the global schema for legacy CRDs.
+func (c crdIsh) schemata() map[string]interface{} {
+	var global interface{}
+	if c.Spec.Validation != nil {
+		global = c.Spec.Validation.OpenAPIV3Schema
+	}
+	if len(c.Spec.Versions) == 0 {
+		return map[string]interface{}{c.Spec.Version: global}
+	}
+
+	res := make(map[string]interface{}, len(c.Spec.Versions))
+	for _, ver := range c.Spec.Versions {
+		res[ver.Name] = global
+		if ver.Schema != nil {
+			res[ver.Name] = ver.Schema.OpenAPIV3Schema
+		}
+	}
+	return res
+}
+
 // legacySchema jumps through some hoops to convert a v1 schema to a v1beta1 schema.
 func legacySchema(origSchema apiext.JSONSchemaProps) (apiextlegacy.JSONSchemaProps, error) {
 	shellCRD := apiext.CustomResourceDefinition{}
@@ -24,6 +24,9 @@ import (
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextlegacy "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"sigs.k8s.io/yaml"
 
 	"sigs.k8s.io/controller-tools/pkg/genall"
 	. "sigs.k8s.io/controller-tools/pkg/schemapatcher"
@@ -71,4 +74,146 @@ var _ = Describe("CRD Patching From Parsing to Editing", func() {
 			Expect(actualContents).To(Equal(expectedContents), "contents not as expected, check pkg/schemapatcher/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(string(actualContents), string(expectedContents)))
 		}
 	})
+
+	It("should add missing versions when asked to", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the generation runtime")
+		var crdSchemaGen genall.Generator = &Generator{
+			ManifestsPath: "./manifests",
+			AddVersions:   true,
+		}
+		rt, err := genall.Generators{&crdSchemaGen}.ForRoots("./...")
+		Expect(err).NotTo(HaveOccurred())
+
+		outputDir, err := ioutil.TempDir("", "controller-tools-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outputDir)
+		rt.OutputRules.Default = genall.OutputToDirectory(outputDir)
+
+		By("running the generator")
+		Expect(rt.Run()).To(BeFalse(), "unexpectedly had errors")
+
+		By("checking the versions of the v1 CRD")
+		rawCRD, err := ioutil.ReadFile(filepath.Join(outputDir, "kubebuilder-example-crd.v1.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		var crd apiext.CustomResourceDefinition
+		Expect(yaml.Unmarshal(rawCRD, &crd)).To(Succeed())
+		Expect(crd.Spec.Versions).To(HaveLen(2))
+		Expect(crd.Spec.Versions[0].Name).To(Equal("v1"))
+		Expect(crd.Spec.Versions[0].Storage).To(BeFalse())
+		Expect(crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties).To(HaveKey("spec"))
+		Expect(crd.Spec.Versions[1].Name).To(Equal("v2"))
+		Expect(crd.Spec.Versions[1].Served).To(BeTrue())
+		Expect(crd.Spec.Versions[1].Storage).To(BeTrue())
+		Expect(crd.Spec.Versions[1].Schema.OpenAPIV3Schema.Properties["spec"].Properties).To(HaveKey("foo"))
+
+		By("checking the versions of the legacy single-version CRD")
+		rawCRD, err = ioutil.ReadFile(filepath.Join(outputDir, "kubebuilder-example-crd.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		var legacyCRD apiextlegacy.CustomResourceDefinition
+		Expect(yaml.Unmarshal(rawCRD, &legacyCRD)).To(Succeed())
+		Expect(legacyCRD.Spec.Validation).To(BeNil())
+		Expect(legacyCRD.Spec.Versions).To(HaveLen(2))
+		Expect(legacyCRD.Spec.Versions[0].Name).To(Equal("v1"))
+		Expect(legacyCRD.Spec.Versions[0].Storage).To(BeFalse())
+		Expect(legacyCRD.Spec.Versions[0].Schema).NotTo(BeNil())
+		Expect(legacyCRD.Spec.Versions[1].Name).To(Equal("v2"))
+		Expect(legacyCRD.Spec.Versions[1].Storage).To(BeTrue())
+	})
+
+	It("should patch printer columns, subresources and names when asked to", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the generation runtime")
+		var crdSchemaGen genall.Generator = &Generator{
+			ManifestsPath: "./manifests",
+			PatchExtras:   true,
+		}
+		rt, err := genall.Generators{&crdSchemaGen}.ForRoots("./...")
+		Expect(err).NotTo(HaveOccurred())
+
+		outputDir, err := ioutil.TempDir("", "controller-tools-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outputDir)
+		rt.OutputRules.Default = genall.OutputToDirectory(outputDir)
+
+		By("running the generator")
+		Expect(rt.Run()).To(BeFalse(), "unexpectedly had errors")
+
+		By("checking the extras of the v1 CRD")
+		rawCRD, err := ioutil.ReadFile(filepath.Join(outputDir, "kubebuilder-example-crd.v1.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		var crd apiext.CustomResourceDefinition
+		Expect(yaml.Unmarshal(rawCRD, &crd)).To(Succeed())
+		Expect(crd.Name).To(Equal("examples.kubebuilder.schemapatcher.controller-tools.sigs.k8s.io"))
+		Expect(crd.Spec.Scope).To(Equal(apiext.ClusterScoped))
+		Expect(crd.Spec.Names.ShortNames).To(Equal([]string{"ex"}))
+		Expect(crd.Spec.Names.Categories).To(Equal([]string{"all"}))
+		Expect(crd.Spec.Versions).To(HaveLen(1))
+		Expect(crd.Spec.Versions[0].Subresources).NotTo(BeNil())
+		Expect(crd.Spec.Versions[0].Subresources.Status).NotTo(BeNil())
+		Expect(crd.Spec.Versions[0].AdditionalPrinterColumns).To(Equal([]apiext.CustomResourceColumnDefinition{
+			{Name: "Foo", Type: "string", JSONPath: ".spec.foo"},
+		}))
+
+		By("checking the extras of the legacy single-version CRD")
+		rawCRD, err = ioutil.ReadFile(filepath.Join(outputDir, "kubebuilder-example-crd.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		var legacyCRD apiextlegacy.CustomResourceDefinition
+		Expect(yaml.Unmarshal(rawCRD, &legacyCRD)).To(Succeed())
+		Expect(legacyCRD.Name).To(Equal("examples.kubebuilder.schemapatcher.controller-tools.sigs.k8s.io"))
+		Expect(legacyCRD.Spec.Names.ShortNames).To(Equal([]string{"ex"}))
+		Expect(legacyCRD.Spec.Versions).To(BeEmpty())
+		Expect(legacyCRD.Spec.Subresources).NotTo(BeNil())
+		Expect(legacyCRD.Spec.Subresources.Status).NotTo(BeNil())
+		Expect(legacyCRD.Spec.AdditionalPrinterColumns).To(Equal([]apiextlegacy.CustomResourceColumnDefinition{
+			{Name: "Foo", Type: "string", JSONPath: ".spec.foo"},
+		}))
+	})
+
+	It("should only report out-of-date schemata in check mode", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		outputDir, err := ioutil.TempDir("", "controller-tools-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(outputDir)
+
+		By("checking the out-of-date manifests")
+		var crdSchemaGen genall.Generator = &Generator{
+			ManifestsPath: "./manifests",
+			Check:         true,
+		}
+		rt, err := genall.Generators{&crdSchemaGen}.ForRoots("./...")
+		Expect(err).NotTo(HaveOccurred())
+		rt.OutputRules.Default = genall.OutputToDirectory(outputDir)
+		Expect(rt.Run()).To(BeTrue(), "expected out-of-date schemata to be reported")
+
+		By("checking the up-to-date manifests")
+		crdSchemaGen = &Generator{
+			ManifestsPath: "./expected",
+			Check:         true,
+		}
+		rt, err = genall.Generators{&crdSchemaGen}.ForRoots("./...")
+		Expect(err).NotTo(HaveOccurred())
+		rt.OutputRules.Default = genall.OutputToDirectory(outputDir)
+		Expect(rt.Run()).To(BeFalse(), "unexpectedly had errors")
+
+		By("checking that nothing was written")
+		written, err := ioutil.ReadDir(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(written).To(BeEmpty())
+	})
 })
@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemapatcher
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Manifests from Helm charts (and similar) contain Go template directives,
+// which generally aren't valid YAML.  We get around this by masking the
+// directives before parsing: lines consisting solely of directives (like
+// `{{- if .Values.foo }}`) are turned into comments, which survive a
+// round-trip through yaml.v3, and directives within a line (like `{{ .Values.bar }}`
+// in a value) are turned into plain scalar placeholders.  After patching,
+// the placeholders get swapped back for the original text.
+
+var (
+	// templateDirective matches a single-line template directive.
+	templateDirective = regexp.MustCompile(`{{.*?}}`)
+	// templatePlaceholder matches the placeholders for template directives,
+	// capturing the index of the masked text.
+	templatePlaceholder = regexp.MustCompile(`__CONTROLLER_GEN_TEMPLATE_(\d+)__`)
+)
+
+// templateMask records the template directives masked out of a manifest.
+type templateMask struct {
+	// masked contains the original text for each placeholder, either a full
+	// line (for directive-only lines) or a single directive.
+	masked []string
+	// wholeLine indicates which placeholders replaced an entire line.
+	wholeLine map[int]bool
+}
+
+// maskTemplates replaces the template directives in the given manifest with
+// YAML-friendly placeholders, returning the masked manifest and a mask for
+// restoring the directives later (nil if no directives were found).
+func maskTemplates(raw []byte) ([]byte, *templateMask) {
+	if !bytes.Contains(raw, []byte("{{")) {
+		return raw, nil
+	}
+
+	mask := &templateMask{wholeLine: make(map[int]bool)}
+	lines := strings.Split(string(raw), "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "{{") {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if templateDirective.ReplaceAllString(trimmed, "") == "" {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			lines[i] = indent + "# " + mask.placeholder(line, true)
+			continue
+		}
+		lines[i] = templateDirective.ReplaceAllStringFunc(line, func(directive string) string {
+			return mask.placeholder(directive, false)
+		})
+	}
+	return []byte(strings.Join(lines, "\n")), mask
+}
+
+// placeholder records the given masked text, returning its placeholder.
+func (m *templateMask) placeholder(text string, wholeLine bool) string {
+	idx := len(m.masked)
+	m.masked = append(m.masked, text)
+	if wholeLine {
+		m.wholeLine[idx] = true
+	}
+	return "__CONTROLLER_GEN_TEMPLATE_" + strconv.Itoa(idx) + "__"
+}
+
+// restore swaps the placeholders in the given (re-encoded) manifest back
+// for the original template directives.  It fails if any of the directives
+// went missing, which happens when they were inside a patched section.
+func (m *templateMask) restore(out []byte) ([]byte, error) {
+	seen := make([]bool, len(m.masked))
+	lines := strings.Split(string(out), "\n")
+	for i, line := range lines {
+		lines[i] = templatePlaceholder.ReplaceAllStringFunc(line, func(placeholder string) string {
+			idx, _ := strconv.Atoi(templatePlaceholder.FindStringSubmatch(placeholder)[1])
+			if idx >= len(m.masked) {
+				return placeholder
+			}
+			seen[idx] = true
+			return m.masked[idx]
+		})
+		// comments may have been re-indented, so use the original
+		// line in its entirety
+		if match := templatePlaceholder.FindStringSubmatch(line); match != nil && strings.TrimSpace(line) == "# "+match[0] {
+			if idx, _ := strconv.Atoi(match[1]); m.wholeLine[idx] {
+				lines[i] = m.masked[idx]
+			}
+		}
+	}
+
+	for idx, wasSeen := range seen {
+		if !wasSeen {
+			return nil, fmt.Errorf("template directive %q was inside a patched section of the manifest", strings.TrimSpace(m.masked[idx]))
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
@@ -21,6 +21,9 @@ import (
 )
 
 // +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=ex,categories=all
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Foo",type=string,JSONPath=`.spec.foo`
 
 // Example is a kind with schema changes.
 type Example struct {
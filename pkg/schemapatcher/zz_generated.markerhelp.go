@@ -29,17 +29,29 @@ func (Generator) Help() *markers.DefinitionHelp {
 		Category: "",
 		DetailedHelp: markers.DetailedHelp{
 			Summary: "patches existing CRDs with new schemata. ",
-			Details: "For legacy (v1beta1) single-version CRDs, it will simply replace the global schema. \n For legacy (v1beta1) multi-version CRDs, and any v1 CRDs, it will replace schemata of existing versions and *clear the schema* from any versions not specified in the Go code.  It will *not* add new versions, or remove old ones. \n For legacy multi-version CRDs with identical schemata, it will take care of lifting the per-version schema up to the global schema. \n It will generate output for each \"CRD Version\" (API version of the CRD type itself) , e.g. apiextensions/v1beta1 and apiextensions/v1) available.",
+			Details: "For legacy (v1beta1) single-version CRDs, it will simply replace the global schema. \n For legacy (v1beta1) multi-version CRDs, and any v1 CRDs, it will replace schemata of existing versions and *clear the schema* from any versions not specified in the Go code.  It will *not* remove old versions, and will only add new ones if AddVersions is set. \n For legacy multi-version CRDs with identical schemata, it will take care of lifting the per-version schema up to the global schema. \n If PatchExtras is set, it will also patch printer columns, subresources, scope and names from the markers, treating them like schemata: they're replaced on versions present in the Go code, and cleared from the rest. \n It will generate output for each \"CRD Version\" (API version of the CRD type itself) , e.g. apiextensions/v1beta1 and apiextensions/v1) available.",
 		},
 		FieldHelp: map[string]markers.DetailedHelp{
 			"ManifestsPath": markers.DetailedHelp{
-				Summary: "contains the CustomResourceDefinition YAML files.",
-				Details: "",
+				Summary: "contains the CustomResourceDefinition YAML files. ",
+				Details: "Files may also be JSON, or contain several YAML documents, in which case every CRD in them is patched, and other objects are left as-is.",
 			},
 			"MaxDescLen": markers.DetailedHelp{
 				Summary: "specifies the maximum description length for fields in CRD's OpenAPI schema. ",
 				Details: "0 indicates drop the description for all fields completely. n indicates limit the description to at most n characters and truncate the description to closest sentence boundary if it exceeds n characters.",
 			},
+			"AddVersions": markers.DetailedHelp{
+				Summary: "indicates that versions present in the Go code, but not in the existing CRDs, should be added to them. ",
+				Details: "New versions are appended after the existing ones, and are served unless marked with `+kubebuilder:unservedversion`.  If a new version is marked with `+kubebuilder:storageversion`, it replaces the existing storage version.",
+			},
+			"PatchExtras": markers.DetailedHelp{
+				Summary: "indicates that printer columns, subresources (status and scale), scope and names (including short names and categories) should be patched from the markers in addition to the schemata. ",
+				Details: "Since the plural name determines the name of the CRD, the CRD's metadata.name is patched to match.",
+			},
+			"Check": markers.DetailedHelp{
+				Summary: "indicates that, instead of writing out the patched CRDs, the schemata that would be written should be compared against the existing ones, failing with a diff for each group-kind and version that's out of date.  Nothing gets written in this mode.",
+				Details: "",
+			},
 		},
 	}
 }